@@ -0,0 +1,121 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	sudoPath = "/usr/bin/sudo"
+	accessW  = 0x2 // W_OK from unistd.h
+)
+
+// checkWriteAccess tells whether the file can be written to without
+// actually writing anything, and if not, why
+func checkWriteAccess(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := syscall.Access(path, accessW); err == nil {
+		return nil
+	}
+	return fmt.Errorf("%s is not writable: %s", path, describeOwnership(fi))
+}
+
+// checkCreateAccess tells whether the file can be written to, or created if
+// it doesn't exist
+func checkCreateAccess(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return checkWriteAccess(path)
+	}
+	return checkWriteAccess(filepath.Dir(path))
+}
+
+// describeOwnership explains file's owner, group and mode, and whether
+// we are in that group
+func describeOwnership(fi os.FileInfo) string {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fi.Mode().String()
+	}
+	owner := strconv.Itoa(int(st.Uid))
+	if u, err := user.LookupId(owner); err == nil {
+		owner = u.Username
+	}
+	group := strconv.Itoa(int(st.Gid))
+	if g, err := user.LookupGroupId(group); err == nil {
+		group = g.Name
+	}
+	member := "not a member"
+	if inGroup(int(st.Gid)) {
+		member = "a member"
+	}
+	return fmt.Sprintf("%s %s:%s, user is %s of group %s", fi.Mode(), owner, group, member, group)
+}
+
+func inGroup(gid int) bool {
+	if os.Getegid() == gid {
+		return true
+	}
+	groups, err := os.Getgroups()
+	if err != nil {
+		return false
+	}
+	for _, g := range groups {
+		if g == gid {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCommand tells whether the command can be run without anything
+// being actually done; commands run via sudo are checked to be allowed
+// without password
+func checkCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("empty command")
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return err
+	}
+	if filepath.Base(args[0]) != filepath.Base(sudoPath) {
+		return nil
+	}
+	var cmd []string
+	for i, arg := range args[1:] {
+		if !strings.HasPrefix(arg, "-") {
+			cmd = args[i+1:]
+			break
+		}
+	}
+	if len(cmd) == 0 {
+		return nil
+	}
+	if out, err := exec.Command(args[0], append([]string{"-n", "-l"}, cmd...)...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s is not allowed via sudo without password: %s", cmd[0], out)
+	}
+	return nil
+}
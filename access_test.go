@@ -0,0 +1,51 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckWriteAccess(t *testing.T) {
+	dir := t.TempDir()
+	rw := filepath.Join(dir, "rw")
+	ro := filepath.Join(dir, "ro")
+	if err := os.WriteFile(rw, []byte("1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ro, []byte("1"), 0444); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkWriteAccess(rw); err != nil {
+		t.Errorf("%s: %v", rw, err)
+	}
+	if err := checkWriteAccess(filepath.Join(dir, "nonexistent")); err == nil {
+		t.Error("no error for nonexistent file")
+	}
+	if os.Geteuid() != 0 {
+		if err := checkWriteAccess(ro); err == nil {
+			t.Error("no error for read-only file")
+		}
+	}
+
+	b, _ := os.ReadFile(rw)
+	if string(b) != "1" {
+		t.Errorf("file was written to: %q", b)
+	}
+}
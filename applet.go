@@ -18,6 +18,7 @@ package main
 import (
 	"io"
 	"os"
+	"strings"
 
 	"github.com/andlabs/ui"
 	"github.com/getlantern/systray"
@@ -31,12 +32,30 @@ const (
 )
 
 var (
-	appQuit = make(chan struct{})
+	appQuit        = make(chan struct{})
+	stateChangedCh = make(chan struct{}, 1)
 )
 
+// stateChanged makes the tray menu and the window (if any) show the current
+// state
+func stateChanged() {
+	select {
+	case stateChangedCh <- struct{}{}:
+	default:
+	}
+	if mainWindow != nil {
+		ui.QueueMain(refreshWindow)
+	}
+}
+
 func onReady() {
 	logTrace.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "PreparingTray", Other: "Setting up menu..."}}))
 	systray.SetIcon(getIcon(iconPath, defaultIcon))
+	mNothing := systray.AddMenuItem(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "NothingFound", Other: "No supported hardware found"}}), "")
+	mNothing.Disable()
+	mPolicy := systray.AddMenuItem(policy.lockedReason(), "")
+	mPolicy.Disable()
+	mConflict := systray.AddMenuItem("", "")
 	mKbdlightTimeout := systray.AddMenuItem("", "")
 	systray.AddSeparator()
 	mStatus := systray.AddMenuItem("", "")
@@ -45,68 +64,162 @@ func onReady() {
 	mTravel := systray.AddMenuItem(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "DoTravel", Other: "TRAVEL (95%-100%)"}}), "Set battery protection to TRAVEL")
 	mOffice := systray.AddMenuItem(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "DoOffice", Other: "OFFICE (70%-90%)"}}), "Set battery protection to OFFICE")
 	mHome := systray.AddMenuItem(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "DoHome", Other: "HOME (40%-70%)"}}), "Set battery protection to HOME")
+	// the presets from the configuration file, by name
+	mPresets := map[*systray.MenuItem]string{}
+	for _, p := range customPresets() {
+		mPresets[systray.AddMenuItem(presetTitle(p), "Set battery protection to "+strings.ToUpper(p.name))] = p.name
+	}
 	mCustom := systray.AddMenuItem(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "DoCustom", Other: "CUSTOM"}}), "Set custom battery protection thresholds")
+	mTopUp := systray.AddMenuItem("", "Switch off battery protection until the battery is full")
+	mCalibrate := systray.AddMenuItem("", "Charge, discharge and charge the battery fully to calibrate its gauge")
+	mPlanned := systray.AddMenuItem("", "Next switch planned according to the calendar")
+	mPlanned.Disable()
+	mSuggest := systray.AddMenuItem("", "")
+	mHealth := systray.AddMenuItem("", "Keeping the battery fully charged wears it out faster")
+	mHealthDismiss := systray.AddMenuItem(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "DismissWarning", Other: "Dismiss the warning"}}), "")
 	systray.AddSeparator()
 	mFnlock := systray.AddMenuItem("", "")
+	mUndo := systray.AddMenuItem("", "Revert the last change")
 	systray.AddSeparator()
 	mQuit := systray.AddMenuItem(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "Quit", Other: "Quit"}}), "Quit the applet")
-	if config.kdblightTimeout == nil {
-		mKbdlightTimeout.Hide()
-		logTrace.Println("no access to kbdlight_timeout setting, not showing its GUI")
-	} else {
-		mKbdlightTimeout.SetTitle(getKbdlightTimeoutStatus())
-		if !config.kdblightTimeout.isWritable() {
-			mKbdlightTimeout.Disable()
+
+	// showAvailable shows the menu items for the endpoints that are
+	// currently available and hides the rest
+	showAvailable := func() {
+		s := cachedStatus()
+		showItem(mNothing, nothingFound())
+		showItem(mPolicy, policy.active())
+		found := currentConflicts()
+		showItem(mConflict, len(found) > 0)
+		if len(found) > 0 {
+			mConflict.SetTitle(conflictsTitle(found) + " " + localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "ClickToTakeOver", Other: "(click to take over)"}}))
+			mConflict.SetTooltip(conflictsDetails(found))
+			enableItem(mConflict, s.thresh && s.threshWritable)
+		}
+		showItem(mKbdlightTimeout, s.kdblightTimeout)
+		if s.kdblightTimeout {
+			mKbdlightTimeout.SetTitle(getKbdlightTimeoutStatus())
+			enableItem(mKbdlightTimeout, s.kdblightTimeoutWritable && policy.KbdlightTimeout == nil)
+		}
+		showItem(mStatus, s.thresh)
+		if s.thresh {
+			mStatus.SetTitle(statusWithEstimate())
+		}
+		canSet := s.thresh && s.threshWritable
+		for _, item := range []*systray.MenuItem{mOff, mTravel, mOffice, mHome, mCustom, mTopUp, mCalibrate} {
+			showItem(item, canSet)
+		}
+		for item := range mPresets {
+			showItem(item, canSet)
+		}
+		if canSet {
+			for item, name := range map[*systray.MenuItem]string{mOff: "off", mTravel: "travel", mOffice: "office", mHome: "home", mCustom: "custom"} {
+				enableItem(item, policy.checkPreset(name) == nil)
+			}
+			for item, name := range mPresets {
+				enableItem(item, policy.checkPreset(name) == nil)
+			}
+			enableItem(mTopUp, policy.checkThresholds(0, 100) == nil)
+			enableItem(mCalibrate, policy.checkThresholds(0, 100) == nil)
+			mTopUp.SetTitle(topUpTitle())
+			mCalibrate.SetTitle(calibrationTitle())
+		}
+		travel := travelStatus()
+		showItem(mPlanned, canSet && travel != "")
+		if travel != "" {
+			mPlanned.SetTitle(travel)
+		}
+		rec := currentRecommendation()
+		showItem(mSuggest, canSet && rec != nil)
+		if rec != nil {
+			mSuggest.SetTitle(recommendationTitle(rec))
+			mSuggest.SetTooltip(rec.reason)
+		}
+		since, warn := currentHealthWarning()
+		showItem(mHealth, canSet && warn)
+		showItem(mHealthDismiss, canSet && warn)
+		if warn {
+			mHealth.SetTitle(healthTitle(since))
+		}
+		showItem(mFnlock, s.fnlock)
+		if s.fnlock {
+			mFnlock.SetTitle(getFnlockStatus())
+			enableItem(mFnlock, policy.checkFnlock(!s.fnlockState) == nil)
+		}
+		undo, ok := lastUndo()
+		showItem(mUndo, ok)
+		if ok {
+			mUndo.SetTitle(undoTitle(undo))
+			enableItem(mUndo, undo.allowed() == nil)
 		}
 	}
-	if config.thresh == nil {
-		mStatus.Hide()
-		logTrace.Println("no access to BP information, not showing it")
-	} else {
-		mStatus.SetTitle(getStatus())
-	}
-	if config.thresh == nil || !config.thresh.isWritable() {
-		mOff.Hide()
-		mTravel.Hide()
-		mOffice.Hide()
-		mHome.Hide()
-		mCustom.Hide()
-		logTrace.Println("no way to change BP settings, not showing the corresponding GUI")
-	}
-	if config.fnlock == nil {
-		mFnlock.Hide()
-		logTrace.Println("no access to Fn-Lock setting, not showing its GUI")
-	} else {
-		mFnlock.SetTitle(getFnlockStatus())
-	}
+	showAvailable()
 
 	logTrace.Println("Menu is now ready")
+	for item, name := range mPresets {
+		go func(item *systray.MenuItem, name string) {
+			for range item.ClickedCh {
+				logTrace.Println("Got a click on BP", strings.ToUpper(name))
+				trayApplyPreset(mStatus, name)
+			}
+		}(item, name)
+	}
 	go func() {
 		for {
 			select {
 			case <-mStatus.ClickedCh:
 				logTrace.Println("Got a click on BP status")
-				mStatus.SetTitle(getStatus())
+				refreshStatus()
+				mStatus.SetTitle(statusWithEstimate())
 			case <-mOff.ClickedCh:
 				logTrace.Println("Got a click on BP OFF")
-				setThresholds(0, 100)
-				mStatus.SetTitle(getStatus())
+				trayApplyPreset(mStatus, "off")
 			case <-mTravel.ClickedCh:
 				logTrace.Println("Got a click on BP TRAVEL")
-				setThresholds(95, 100)
-				mStatus.SetTitle(getStatus())
+				trayApplyPreset(mStatus, "travel")
 			case <-mOffice.ClickedCh:
 				logTrace.Println("Got a click on BP OFFICE")
-				setThresholds(70, 90)
-				mStatus.SetTitle(getStatus())
+				trayApplyPreset(mStatus, "office")
 			case <-mHome.ClickedCh:
 				logTrace.Println("Got a click on BP HOME")
-				setThresholds(40, 70)
-				mStatus.SetTitle(getStatus())
+				trayApplyPreset(mStatus, "home")
+			case <-mConflict.ClickedCh:
+				logTrace.Println("Got a click on conflict warning")
+				err := takeOver()
+				showTrayResult(mStatus, statusWithEstimate(), err)
+			case <-mTopUp.ClickedCh:
+				logTrace.Println("Got a click on charge fully once")
+				err := toggleTopUp()
+				showTrayResult(mStatus, statusWithEstimate(), err)
+				mTopUp.SetTitle(topUpTitle())
+			case <-mCalibrate.ClickedCh:
+				logTrace.Println("Got a click on calibrate battery")
+				err := toggleCalibration()
+				showTrayResult(mStatus, statusWithEstimate(), err)
+				mCalibrate.SetTitle(calibrationTitle())
+			case <-mSuggest.ClickedCh:
+				logTrace.Println("Got a click on suggested thresholds")
+				err := applyRecommendation()
+				showTrayResult(mStatus, statusWithEstimate(), err)
+			case <-mHealth.ClickedCh:
+				logTrace.Println("Got a click on health warning")
+				err := applyHealthPreset()
+				showTrayResult(mStatus, statusWithEstimate(), err)
+			case <-mHealthDismiss.ClickedCh:
+				logTrace.Println("Got a click on dismiss health warning")
+				if err := dismissHealthWarning(); err != nil {
+					logWarning.Println(err)
+				}
 			case <-mFnlock.ClickedCh:
 				logTrace.Println("Got a click on fnlock")
-				config.fnlock.toggle()
-				mFnlock.SetTitle(getFnlockStatus())
+				err := toggleFnlock(sourceUser)
+				showTrayResult(mFnlock, getFnlockStatus(), err)
+			case <-mUndo.ClickedCh:
+				logTrace.Println("Got a click on undo")
+				err := undoLast()
+				showTrayResult(mStatus, statusWithEstimate(), err)
+			case <-stateChangedCh:
+				showAvailable()
 			case <-appQuit:
 				logTrace.Println("Shutting down systray applet")
 				systray.Quit()
@@ -119,6 +232,9 @@ func onReady() {
 		ui.OnShouldQuit(func() bool {
 			customWindow.Destroy()
 			kbdlightTimeoutWindow.Destroy()
+			if mainWindow != nil {
+				mainWindow.Destroy()
+			}
 			logTrace.Println("ready to quit GUI thread")
 			return true
 		})
@@ -127,21 +243,22 @@ func onReady() {
 				select {
 				case <-mCustom.ClickedCh:
 					logTrace.Println("Got a click on BP CUSTOM")
-					ch := make(chan struct{})
+					ch := make(chan error, 1)
 					ui.QueueMain(func() { customThresholds(ch) })
-					<-ch
-					mStatus.SetTitle(getStatus())
+					err := <-ch
+					showTrayResult(mStatus, statusWithEstimate(), err)
 				case <-mKbdlightTimeout.ClickedCh:
 					logTrace.Println("Got a click on KbdlightTimeout")
-					ch := make(chan struct{})
+					ch := make(chan error, 1)
 					ui.QueueMain(func() { kbdlightTimeout(ch) })
-					<-ch
-					mKbdlightTimeout.SetTitle(getKbdlightTimeoutStatus())
+					err := <-ch
+					showTrayResult(mKbdlightTimeout, getKbdlightTimeoutStatus(), err)
 				case <-appQuit:
 					return
 				}
 			}
 		}()
+		listenInstanceCommands()
 		go func() {
 			<-mQuit.ClickedCh
 			logTrace.Println("Got a click on Quit")
@@ -152,10 +269,47 @@ func onReady() {
 		logError.Println(err)
 	}
 	logInfo.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "AppletExit", Other: "Exiting the applet..."}}))
+	pauseCalibration()
 	os.Exit(0)
 }
 
 func onExit() {
+	pauseCalibration()
+}
+
+func showItem(item *systray.MenuItem, show bool) {
+	if show {
+		item.Show()
+	} else {
+		item.Hide()
+	}
+}
+
+func enableItem(item *systray.MenuItem, enable bool) {
+	if enable {
+		item.Enable()
+	} else {
+		item.Disable()
+	}
+}
+
+// trayApplyPreset applies the preset and shows the result in the status
+// menu item
+func trayApplyPreset(mStatus *systray.MenuItem, name string) {
+	err := applyPreset(name, sourceUser)
+	showTrayResult(mStatus, statusWithEstimate(), err)
+}
+
+// showTrayResult sets menu item title to the status, marking it if the
+// change has failed
+func showTrayResult(item *systray.MenuItem, status string, err error) {
+	if err == nil {
+		item.SetTitle(status)
+		item.SetTooltip("")
+		return
+	}
+	item.SetTitle(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "StatusAfterError", Other: "{{.Status}} (change FAILED)"}, TemplateData: map[string]interface{}{"Status": status}}))
+	item.SetTooltip(err.Error())
 }
 
 func getIcon(pth, dflt string) []byte {
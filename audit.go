@@ -0,0 +1,131 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	auditFile = "audit.jsonl"
+
+	auditThresholds      = "thresholds"
+	auditFnlock          = "fnlock"
+	auditKbdlightTimeout = "kbdlight_timeout"
+	auditUnknown         = "unknown"
+)
+
+// changeSource tells what has asked for a setting to be changed
+type changeSource string
+
+const (
+	sourceUser        changeSource = "user"
+	sourceCommand     changeSource = "command"
+	sourceSchedule    changeSource = "schedule"
+	sourceRestore     changeSource = "restore"
+	sourceSaved       changeSource = "saved"
+	sourceTopUp       changeSource = "top-up"
+	sourceCalibration changeSource = "calibration"
+	sourceTakeOver    changeSource = "take-over"
+	sourcePolicy      changeSource = "policy"
+	sourceUndo        changeSource = "undo"
+	sourceImport      changeSource = "import"
+)
+
+// auditRecord is an attempt to change a setting
+type auditRecord struct {
+	Time    time.Time    `json:"time"`
+	Source  changeSource `json:"source"`
+	Setting string       `json:"setting"`
+	Old     string       `json:"old"`
+	New     string       `json:"new"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// audit appends the attempt to change a setting and its outcome to the
+// audit log
+func audit(src changeSource, setting, old, new string, err error) {
+	r := auditRecord{Time: time.Now(), Source: src, Setting: setting, Old: old, New: new}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	if err := appendState(auditFile, r); err != nil {
+		logWarning.Println("failed to write the audit log:", err)
+	}
+}
+
+// auditValue is the value to record, unless it couldn't be read
+func auditValue(v string, err error) string {
+	if err != nil {
+		return auditUnknown
+	}
+	return v
+}
+
+func formatThresholds(min, max int) string {
+	return fmt.Sprintf("%d-%d", min, max)
+}
+
+// loadAudit reads the audit log, skipping the records that can't be parsed
+func loadAudit() ([]auditRecord, error) {
+	path, err := statePath(auditFile)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []auditRecord
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var r auditRecord
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, sc.Err()
+}
+
+// printHistory prints the audit log as a table
+func printHistory(w io.Writer) error {
+	records, err := loadAudit()
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tSOURCE\tSETTING\tOLD\tNEW\tRESULT")
+	for _, r := range records {
+		result := "ok"
+		if r.Error != "" {
+			result = r.Error
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", r.Time.Local().Format("2006-01-02 15:04:05"), r.Source, r.Setting, r.Old, r.New, result)
+	}
+	return tw.Flush()
+}
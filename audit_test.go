@@ -0,0 +1,96 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAudit(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	policy = policySettings{Max: []int{60, 90}}
+	defer func() { policy = policySettings{} }()
+	drv := &mockDriver{40, 70}
+	config.thresh, config.threshWritable = threshDriver{drv}, true
+	defer func() { config.thresh, config.threshWritable = nil, false }()
+
+	if err := writeThresholds(70, 90, sourceSchedule); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeThresholds(0, 100, sourceUser); err == nil {
+		t.Fatal("thresholds set against the policy")
+	}
+
+	records, err := loadAudit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("want 2 records, got %d", len(records))
+	}
+	want := []auditRecord{
+		{Source: sourceSchedule, Setting: auditThresholds, Old: "40-70", New: "70-90"},
+		{Source: sourceUser, Setting: auditThresholds, Old: "70-90", New: "0-100"},
+	}
+	for i, r := range records {
+		if r.Source != want[i].Source || r.Setting != want[i].Setting || r.Old != want[i].Old || r.New != want[i].New {
+			t.Errorf("record %d: want %+v, got %+v", i, want[i], r)
+		}
+	}
+	if records[0].Error != "" || records[1].Error == "" {
+		t.Errorf("wrong outcomes: %q, %q", records[0].Error, records[1].Error)
+	}
+
+	var buf bytes.Buffer
+	if err := printHistory(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 3 || !strings.Contains(lines[1], "schedule") || !strings.Contains(lines[1], "ok") {
+		t.Errorf("unexpected history:\n%s", buf.String())
+	}
+}
+
+func TestAuditUnreadable(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	settings.VerifyTimeout = 10 * time.Millisecond
+	defer func() { settings.VerifyTimeout = defaultVerifyTimeout }()
+	appNotifier = &mockNotifier{}
+	config.thresh, config.threshWritable = threshDriver{&unreadableDriver{}}, true
+	defer func() { config.thresh, config.threshWritable = nil, false }()
+
+	_ = writeThresholds(70, 90, sourceUser)
+
+	records, err := loadAudit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Old != auditUnknown || records[0].New != "70-90" {
+		t.Fatalf("want old value %q, got %+v", auditUnknown, records)
+	}
+}
+
+// unreadableDriver takes the writes, but can't read the thresholds back
+type unreadableDriver struct {
+	mockDriver
+}
+
+func (drv *unreadableDriver) get() (min, max int, err error) {
+	return 0, 0, errors.New("read failed")
+}
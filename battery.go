@@ -0,0 +1,188 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	powerSupplyPath = "/sys/class/power_supply/"
+
+	chargeAuto           = "auto"
+	chargeForceDischarge = "force-discharge"
+)
+
+var errNoBattery = errors.New("no battery found")
+
+// batteryInfo is what power_supply class reports about the battery
+type batteryInfo struct {
+	capacity int
+	status   string
+}
+
+// findBattery returns the sysfs directory of the first battery found
+func findBattery() (string, error) {
+	dirs, err := filepath.Glob(powerSupplyPath + "BAT*")
+	if err != nil {
+		return "", err
+	}
+	for _, dir := range dirs {
+		if _, err := os.Stat(filepath.Join(dir, "capacity")); err == nil {
+			return dir, nil
+		}
+	}
+	return "", errNoBattery
+}
+
+func getBattery() (batteryInfo, error) {
+	var info batteryInfo
+	dir, err := findBattery()
+	if err != nil {
+		return info, err
+	}
+	info.capacity, err = readSysfsInt(filepath.Join(dir, "capacity"))
+	if err != nil {
+		return info, err
+	}
+	info.status, err = readSysfsString(filepath.Join(dir, "status"))
+	return info, err
+}
+
+func readSysfsString(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func readSysfsInt(path string) (int, error) {
+	s, err := readSysfsString(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(s)
+}
+
+// full tells whether the battery is charged fully
+func (b batteryInfo) full() bool {
+	return b.status == "Full" || b.capacity >= 100
+}
+
+// energyFull describes the battery full capacity as last measured by the
+// gauge, in Wh or mAh, depending on what the battery reports
+func energyFull(dir string) (string, error) {
+	if uwh, err := readSysfsInt(filepath.Join(dir, "energy_full")); err == nil {
+		return fmt.Sprintf("%.1f Wh", float64(uwh)/1e6), nil
+	}
+	uah, err := readSysfsInt(filepath.Join(dir, "charge_full"))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d mAh", uah/1000), nil
+}
+
+// chargeBehaviours parses charge_behaviour file contents, such as
+// "[auto] inhibit-charge force-discharge", into the current value and the
+// ones available
+func chargeBehaviours(s string) (current string, available []string) {
+	for _, v := range strings.Fields(s) {
+		if strings.HasPrefix(v, "[") && strings.HasSuffix(v, "]") {
+			v = strings.Trim(v, "[]")
+			current = v
+		}
+		available = append(available, v)
+	}
+	return
+}
+
+// canForceDischarge tells whether the battery can be discharged while on AC
+func canForceDischarge(dir string) bool {
+	s, err := readSysfsString(filepath.Join(dir, "charge_behaviour"))
+	if err != nil {
+		return false
+	}
+	_, available := chargeBehaviours(s)
+	for _, v := range available {
+		if v == chargeForceDischarge {
+			return true
+		}
+	}
+	return false
+}
+
+func setChargeBehaviour(dir, v string) error {
+	return os.WriteFile(filepath.Join(dir, "charge_behaviour"), []byte(v), 0644)
+}
+
+// acOnline tells whether the laptop is plugged in
+func acOnline() (bool, error) {
+	dirs, err := filepath.Glob(powerSupplyPath + "*")
+	if err != nil {
+		return false, err
+	}
+	for _, dir := range dirs {
+		if t, err := readSysfsString(filepath.Join(dir, "type")); err != nil || t != "Mains" {
+			continue
+		}
+		online, err := readSysfsInt(filepath.Join(dir, "online"))
+		if err != nil {
+			return false, err
+		}
+		return online == 1, nil
+	}
+	return false, errors.New("no AC adapter found")
+}
+
+// batteryReading is the battery state needed to estimate charging time;
+// the amounts are in µWh and µW or in µAh and µA, whichever the battery
+// reports
+type batteryReading struct {
+	batteryInfo
+	now, full, rate int
+}
+
+func readBattery() (batteryReading, error) {
+	var r batteryReading
+	dir, err := findBattery()
+	if err != nil {
+		return r, err
+	}
+	if r.batteryInfo, err = getBattery(); err != nil {
+		return r, err
+	}
+	for _, names := range [][3]string{{"energy_now", "energy_full", "power_now"}, {"charge_now", "charge_full", "current_now"}} {
+		if r.now, err = readSysfsInt(filepath.Join(dir, names[0])); err != nil {
+			continue
+		}
+		if r.full, err = readSysfsInt(filepath.Join(dir, names[1])); err != nil {
+			return r, err
+		}
+		r.rate, err = readSysfsInt(filepath.Join(dir, names[2]))
+		if r.rate < 0 {
+			// some drivers report discharging current as negative
+			r.rate = -r.rate
+		}
+		return r, err
+	}
+	return r, err
+}
@@ -0,0 +1,351 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	defaultCalibrationLow    = 5
+	calibrationCheckInterval = time.Minute
+	calibrationStateFile     = "calibration.json"
+
+	// calibration goes through these stages in this order
+	stageCharge    = "charge"
+	stageDischarge = "discharge"
+	stageRecharge  = "recharge"
+	stageDone      = "done"
+)
+
+// calibrationSettings define how the battery is calibrated
+type calibrationSettings struct {
+	LowLevel       int  `toml:"low_level"`
+	ForceDischarge bool `toml:"force_discharge"`
+}
+
+// calibrationState is the calibration in progress, it is kept in a file so
+// that it can be resumed after an applet restart
+type calibrationState struct {
+	Min    int    `json:"min"`
+	Max    int    `json:"max"`
+	Stage  string `json:"stage"`
+	Forced bool   `json:"forced"`
+	// the applet has quit while forcing the battery to discharge
+	Paused bool   `json:"paused,omitempty"`
+	Before string `json:"energy_full_before"`
+}
+
+var calibrationMutex sync.Mutex
+
+func loadCalibration() (*calibrationState, error) {
+	var s calibrationState
+	if ok, err := readState(calibrationStateFile, &s); !ok || err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// calibrationInProgress returns the calibration in progress, or nil if
+// there's none
+func calibrationInProgress() *calibrationState {
+	calibrationMutex.Lock()
+	defer calibrationMutex.Unlock()
+	s, err := loadCalibration()
+	if err != nil {
+		logTrace.Println(err)
+	}
+	return s
+}
+
+func calibrationLow() int {
+	if settings.Calibration.LowLevel <= 0 || settings.Calibration.LowLevel >= 100 {
+		return defaultCalibrationLow
+	}
+	return settings.Calibration.LowLevel
+}
+
+// startCalibration switches battery protection off and asks the user to
+// charge the battery fully
+func startCalibration() error {
+	if topUpInProgress() != nil {
+		return errors.New(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "TopUpInProgress", Other: "Already charging fully"}}))
+	}
+	calibrationMutex.Lock()
+	defer calibrationMutex.Unlock()
+	if s, _ := loadCalibration(); s != nil {
+		return errors.New(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CalibrationInProgress", Other: "Battery calibration is already in progress"}}))
+	}
+	refreshStatus()
+	st := cachedStatus()
+	if !st.thresh || !st.threshWritable || st.threshErr != nil {
+		return errNotAvailable()
+	}
+	dir, err := findBattery()
+	if err != nil {
+		return err
+	}
+	before, err := energyFull(dir)
+	if err != nil {
+		return err
+	}
+
+	s := calibrationState{Min: st.min, Max: st.max, Stage: stageCharge, Before: before}
+	if err := writeState(calibrationStateFile, s); err != nil {
+		return err
+	}
+	if err := setThresholds(0, 100, sourceCalibration); err != nil {
+		removeState(calibrationStateFile)
+		return err
+	}
+	msg := localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CalibrationStarted", Other: "Battery calibration: plug in the charger and let the battery charge fully"}})
+	logInfo.Println(msg)
+	sendNotification(eventCalibration, msg)
+	stateChanged()
+	return nil
+}
+
+// cancelCalibration stops discharging (if the battery is being discharged)
+// and restores the thresholds right away
+func cancelCalibration() error {
+	calibrationMutex.Lock()
+	defer calibrationMutex.Unlock()
+	s, err := loadCalibration()
+	if err != nil || s == nil {
+		return err
+	}
+	var stopErr error
+	hwDo(func() {
+		stopErr = stopForceDischarge(s)
+		if err = writeThresholds(s.Min, s.Max, sourceCalibration); err == nil {
+			updateStatus()
+		}
+	})
+	if stopErr != nil {
+		return stopErr
+	}
+	if err != nil {
+		return err
+	}
+	removeState(calibrationStateFile)
+	logInfo.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CalibrationCancelled", Other: "Battery calibration cancelled, thresholds restored"}}))
+	stateChanged()
+	return nil
+}
+
+// toggleCalibration starts the calibration or cancels the one in progress
+func toggleCalibration() error {
+	if calibrationInProgress() != nil {
+		return cancelCalibration()
+	}
+	return startCalibration()
+}
+
+// nextStage returns the calibration stage to go to given the battery state
+func nextStage(stage string, bat batteryInfo, low int) string {
+	switch stage {
+	case stageCharge:
+		if bat.full() {
+			return stageDischarge
+		}
+	case stageDischarge:
+		if bat.capacity <= low {
+			return stageRecharge
+		}
+	case stageRecharge:
+		if bat.full() {
+			return stageDone
+		}
+	}
+	return stage
+}
+
+// stopForceDischarge lets the battery charge again if calibration has
+// forced it to discharge; it must only be run in the hardware goroutine
+func stopForceDischarge(s *calibrationState) error {
+	if !s.Forced {
+		return nil
+	}
+	dir, err := findBattery()
+	if err != nil {
+		return err
+	}
+	if err := setChargeBehaviour(dir, chargeAuto); err != nil {
+		return err
+	}
+	s.Forced = false
+	return nil
+}
+
+// checkCalibration moves the calibration on to the next stage when the
+// battery is ready for it, returning the message to notify the user with
+// (if any); it must only be run in the hardware goroutine
+func checkCalibration(s *calibrationState) (string, error) {
+	if config.thresh == nil {
+		// the driver may show up later
+		return "", nil
+	}
+	min, max, err := config.thresh.get()
+	if err != nil {
+		return "", err
+	}
+	if !sameThresholds(min, max, 0, 100) {
+		logInfo.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CalibrationOverridden", Other: "Thresholds were changed during battery calibration, calibration stopped"}}))
+		// the battery must not be left discharging on AC
+		if err := stopForceDischarge(s); err != nil {
+			return "", err
+		}
+		s.Stage = stageDone
+		return "", nil
+	}
+	dir, err := findBattery()
+	if err != nil {
+		return "", err
+	}
+	if s.Paused {
+		s.Paused = false
+		if err := setChargeBehaviour(dir, chargeForceDischarge); err != nil {
+			logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantForceDischarge", Other: "Failed to force the battery to discharge: {{.Error}}"}, TemplateData: map[string]interface{}{"Error": err}}))
+		} else {
+			s.Forced = true
+		}
+	}
+	bat, err := getBattery()
+	if err != nil {
+		return "", err
+	}
+
+	low := calibrationLow()
+	next := nextStage(s.Stage, bat, low)
+	if next == s.Stage {
+		return "", nil
+	}
+	var msg string
+	switch next {
+	case stageDischarge:
+		if settings.Calibration.ForceDischarge && canForceDischarge(dir) {
+			if err := setChargeBehaviour(dir, chargeForceDischarge); err != nil {
+				logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantForceDischarge", Other: "Failed to force the battery to discharge: {{.Error}}"}, TemplateData: map[string]interface{}{"Error": err}}))
+			} else {
+				s.Forced = true
+			}
+		}
+		if s.Forced {
+			msg = localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CalibrationDischarging", Other: "Battery calibration: the battery is full, discharging it down to {{.Low}}%"}, TemplateData: map[string]interface{}{"Low": low}})
+		} else {
+			msg = localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CalibrationUnplug", Other: "Battery calibration: the battery is full, unplug the charger and use the laptop until the battery is down to {{.Low}}%"}, TemplateData: map[string]interface{}{"Low": low}})
+		}
+	case stageRecharge:
+		if err := stopForceDischarge(s); err != nil {
+			return "", err
+		}
+		msg = localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CalibrationRecharge", Other: "Battery calibration: the battery is down to {{.Capacity}}%, plug in the charger and let it charge fully"}, TemplateData: map[string]interface{}{"Capacity": bat.capacity}})
+	case stageDone:
+		after, err := energyFull(dir)
+		if err != nil {
+			return "", err
+		}
+		if err := writeThresholds(s.Min, s.Max, sourceCalibration); err != nil {
+			return "", err
+		}
+		updateStatus()
+		msg = localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CalibrationDone", Other: "Battery calibration done: full capacity was {{.Before}}, now {{.After}}; thresholds {{.Min}}%-{{.Max}}% restored"}, TemplateData: map[string]interface{}{"Before": s.Before, "After": after, "Min": s.Min, "Max": s.Max}})
+	}
+	s.Stage = next
+	return msg, nil
+}
+
+// pauseCalibration lets the battery charge again when the applet quits
+// while forcing it to discharge, the discharge is forced again when the
+// calibration is resumed
+func pauseCalibration() {
+	calibrationMutex.Lock()
+	defer calibrationMutex.Unlock()
+	s, err := loadCalibration()
+	if err != nil || s == nil || !s.Forced {
+		return
+	}
+	hwDo(func() { err = stopForceDischarge(s) })
+	if err != nil {
+		logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantStopForceDischarge", Other: "Failed to let the battery charge again, it is still being discharged: {{.Error}}"}, TemplateData: map[string]interface{}{"Error": err}}))
+		return
+	}
+	s.Paused = true
+	if err := writeState(calibrationStateFile, s); err != nil {
+		logWarning.Println(err)
+	}
+}
+
+// watchCalibration checks on the calibration in progress (if any),
+// including the one started before the applet was restarted
+func watchCalibration() {
+	if s := calibrationInProgress(); s != nil {
+		logInfo.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CalibrationResumed", Other: "Resuming battery calibration"}}))
+	}
+	for {
+		calibrationTick()
+		time.Sleep(calibrationCheckInterval)
+	}
+}
+
+func calibrationTick() {
+	calibrationMutex.Lock()
+	defer calibrationMutex.Unlock()
+	s, err := loadCalibration()
+	if err != nil {
+		logTrace.Println(err)
+		return
+	}
+	if s == nil {
+		return
+	}
+	var msg string
+	hwDo(func() { msg, err = checkCalibration(s) })
+	if err != nil {
+		logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CalibrationFailed", Other: "Battery calibration: {{.Error}}"}, TemplateData: map[string]interface{}{"Error": err}}))
+	}
+	if s.Stage == stageDone {
+		removeState(calibrationStateFile)
+	} else if err := writeState(calibrationStateFile, s); err != nil {
+		logWarning.Println(err)
+	}
+	if msg != "" {
+		logInfo.Println(msg)
+		sendNotification(eventCalibration, msg)
+		stateChanged()
+	}
+}
+
+// calibrationTitle returns the title for the UI element that starts or
+// cancels the calibration
+func calibrationTitle() string {
+	s := calibrationInProgress()
+	if s == nil {
+		return localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CalibrationStart", Other: "Calibrate battery"}})
+	}
+	var stage string
+	switch s.Stage {
+	case stageCharge, stageRecharge:
+		stage = localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CalibrationStageCharge", Other: "charging fully"}})
+	case stageDischarge:
+		stage = localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CalibrationStageDischarge", Other: "discharging to {{.Low}}%"}, TemplateData: map[string]interface{}{"Low": calibrationLow()}})
+	}
+	return localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CalibrationCancel", Other: "Calibrating battery: {{.Stage}} (click to cancel)"}, TemplateData: map[string]interface{}{"Stage": stage}})
+}
@@ -0,0 +1,70 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestNextStage(t *testing.T) {
+	tests := []struct {
+		stage string
+		bat   batteryInfo
+		want  string
+	}{
+		{stageCharge, batteryInfo{80, "Charging"}, stageCharge},
+		{stageCharge, batteryInfo{100, "Full"}, stageDischarge},
+		{stageCharge, batteryInfo{98, "Full"}, stageDischarge},
+		{stageDischarge, batteryInfo{40, "Discharging"}, stageDischarge},
+		{stageDischarge, batteryInfo{5, "Discharging"}, stageRecharge},
+		{stageRecharge, batteryInfo{5, "Charging"}, stageRecharge},
+		{stageRecharge, batteryInfo{100, "Not charging"}, stageDone},
+	}
+	for _, tc := range tests {
+		if got := nextStage(tc.stage, tc.bat, 5); got != tc.want {
+			t.Errorf("%s at %v: want %s, got %s", tc.stage, tc.bat, tc.want, got)
+		}
+	}
+}
+
+func TestChargeBehaviours(t *testing.T) {
+	current, available := chargeBehaviours("[auto] inhibit-charge force-discharge\n")
+	if current != chargeAuto {
+		t.Errorf("want: %s, got: %s", chargeAuto, current)
+	}
+	if len(available) != 3 || available[2] != chargeForceDischarge {
+		t.Errorf("want: [auto inhibit-charge force-discharge], got: %v", available)
+	}
+}
+
+func TestCalibrationOverridden(t *testing.T) {
+	if _, err := findBattery(); err == nil {
+		t.Skip("a real battery must not be touched")
+	}
+	config.thresh = threshDriver{&mockDriver{40, 70}}
+	defer func() { config.thresh = nil }()
+
+	// the battery can't be let charge again, so it's not done yet
+	s := &calibrationState{Min: 40, Max: 70, Stage: stageDischarge, Forced: true}
+	if _, err := checkCalibration(s); err == nil || s.Stage != stageDischarge || !s.Forced {
+		t.Fatalf("calibration stopped with force-discharge on: %+v, %v", s, err)
+	}
+
+	s.Forced = false
+	if _, err := checkCalibration(s); err != nil || s.Stage != stageDone {
+		t.Fatalf("calibration not stopped: %+v, %v", s, err)
+	}
+}
@@ -0,0 +1,272 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	defaultRevertDelay = 10 * time.Second
+
+	tlpConfPath         = "/etc/tlp.conf"
+	tlpDropInGlob       = "/etc/tlp.d/*.conf"
+	autoCpufreqConfPath = "/etc/auto-cpufreq.conf"
+	systemdWantsGlob    = "/etc/systemd/system/*.wants/*huawei*"
+	ppdWantsGlob        = "/etc/systemd/system/*.wants/power-profiles-daemon.service"
+)
+
+// conflictSettings define what to do about other tools managing thresholds
+type conflictSettings struct {
+	TakeOver    bool          `toml:"take_over"`
+	RevertDelay time.Duration `toml:"revert_delay"`
+}
+
+// conflict is another tool that manages charge thresholds
+type conflict struct {
+	tool   string
+	detail string
+}
+
+func (c conflict) String() string {
+	return c.tool + ": " + c.detail
+}
+
+var conflicts struct {
+	sync.Mutex
+	found    []conflict
+	reverted *conflict
+
+	// the thresholds the applet has set last
+	min, max int
+	applied  bool
+	retried  bool
+	gen      int
+}
+
+// detectConflicts looks for other tools configured to manage thresholds
+func detectConflicts() []conflict {
+	var found []conflict
+	files, _ := filepath.Glob(tlpDropInGlob)
+	for _, f := range append(files, tlpConfPath) {
+		if f == tlpDropInPath && settings.ThreshPersistence == persistTLP {
+			continue
+		}
+		if vars := tlpThresholds(f); len(vars) > 0 {
+			found = append(found, conflict{tool: "TLP", detail: strings.Join(vars, ", ") + " in " + f})
+		}
+	}
+	if autoCpufreqThresholds(autoCpufreqConfPath) {
+		found = append(found, conflict{tool: "auto-cpufreq", detail: "enable_thresholds in " + autoCpufreqConfPath})
+	}
+	units, _ := filepath.Glob(systemdWantsGlob)
+	for _, u := range units {
+		found = append(found, conflict{tool: "systemd", detail: filepath.Base(u) + " is enabled"})
+	}
+	if bat, err := findBattery(); err == nil && ppdCharging(ppdWantsGlob, bat) {
+		found = append(found, conflict{tool: "power-profiles-daemon", detail: "power-profiles-daemon.service is enabled, its trickle_charge action changes charging in power-saver mode"})
+	}
+	return found
+}
+
+// tlpThresholds returns the charge thresholds settings in TLP config file
+func tlpThresholds(path string) []string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var found []string
+	for k, v := range parseShellVars(b) {
+		if (strings.HasPrefix(k, "START_CHARGE_THRESH_") || strings.HasPrefix(k, "STOP_CHARGE_THRESH_")) && v != "" {
+			found = append(found, k+"="+v)
+		}
+	}
+	// keep the order stable, so that the same settings aren't reported
+	// as a change
+	sort.Strings(found)
+	return found
+}
+
+// ppdCharging tells whether power-profiles-daemon service is enabled and
+// can change how the battery in dir is charged, which it only does through
+// the charge type
+func ppdCharging(glob, dir string) bool {
+	if units, _ := filepath.Glob(glob); len(units) == 0 {
+		return false
+	}
+	for _, attr := range []string{"charge_type", "charge_types"} {
+		if _, err := os.Stat(filepath.Join(dir, attr)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// autoCpufreqThresholds tells whether auto-cpufreq is configured to set
+// charge thresholds
+func autoCpufreqThresholds(path string) bool {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		k, v, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if ok && strings.TrimSpace(k) == "enable_thresholds" && strings.EqualFold(strings.TrimSpace(v), "true") {
+			return true
+		}
+	}
+	return false
+}
+
+// updateConflicts detects conflicting tools, returning true if the set of
+// them has changed
+func updateConflicts() bool {
+	found := detectConflicts()
+	conflicts.Lock()
+	defer conflicts.Unlock()
+	changed := len(found) != len(conflicts.found)
+	for i := 0; !changed && i < len(found); i++ {
+		changed = found[i] != conflicts.found[i]
+	}
+	if changed {
+		for _, c := range found {
+			logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "ConflictFound", Other: "Another tool manages charge thresholds: {{.Conflict}}"}, TemplateData: map[string]interface{}{"Conflict": c}}))
+		}
+	}
+	conflicts.found = found
+	return changed
+}
+
+// currentConflicts returns the conflicting tools known, including the
+// unknown one that has reverted the thresholds
+func currentConflicts() []conflict {
+	conflicts.Lock()
+	defer conflicts.Unlock()
+	found := append([]conflict{}, conflicts.found...)
+	if conflicts.reverted != nil {
+		found = append(found, *conflicts.reverted)
+	}
+	return found
+}
+
+// thresholdsApplied remembers the thresholds the applet has set and makes
+// sure they aren't reverted shortly after
+func thresholdsApplied(min, max int) {
+	conflicts.Lock()
+	// setting the same thresholds again after they were reverted is a retry
+	conflicts.retried = conflicts.applied && conflicts.min == min && conflicts.max == max && conflicts.reverted != nil
+	conflicts.min, conflicts.max, conflicts.applied = min, max, true
+	conflicts.reverted = nil
+	conflicts.gen++
+	gen := conflicts.gen
+	conflicts.Unlock()
+
+	delay := settings.Conflicts.RevertDelay
+	if delay <= 0 {
+		return
+	}
+	time.AfterFunc(delay, func() {
+		var msg string
+		hwDo(func() { msg = checkReverted(gen) })
+		if msg != "" {
+			sendNotification(eventFailure, msg)
+			stateChanged()
+		}
+	})
+}
+
+// checkReverted reads the thresholds back to see if something has changed
+// them since the applet did, taking over if the user wants it; it returns
+// the message to notify the user with (if any) and must only be run in the
+// hardware goroutine
+func checkReverted(gen int) string {
+	conflicts.Lock()
+	min, max, retried := conflicts.min, conflicts.max, conflicts.retried
+	current := conflicts.gen == gen
+	conflicts.Unlock()
+	if !current || config.thresh == nil {
+		// the applet has set something else since
+		return ""
+	}
+	liveMin, liveMax, err := config.thresh.get()
+	if err != nil || sameThresholds(min, max, liveMin, liveMax) {
+		return ""
+	}
+
+	msg := localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "ThresholdsReverted", Other: "Thresholds {{.Min}}%-{{.Max}}% set by the applet were changed to {{.LiveMin}}%-{{.LiveMax}}% by something else"}, TemplateData: map[string]interface{}{"Min": min, "Max": max, "LiveMin": liveMin, "LiveMax": liveMax}})
+	logWarning.Println(msg)
+	conflicts.Lock()
+	conflicts.reverted = &conflict{tool: localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "UnknownTool", Other: "unknown tool"}}), detail: msg}
+	conflicts.Unlock()
+
+	if settings.Conflicts.TakeOver && !retried {
+		logInfo.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "TakingOver", Other: "Re-applying the thresholds set by the applet"}}))
+		writeThresholds(min, max, sourceTakeOver)
+	}
+	updateStatus()
+	return msg
+}
+
+// takeOver re-applies the thresholds the applet has set last (or the saved
+// ones), so that they win over the other tool
+func takeOver() error {
+	var err error
+	hwDo(func() {
+		conflicts.Lock()
+		min, max, ok := conflicts.min, conflicts.max, conflicts.applied
+		conflicts.Unlock()
+		if !ok && config.threshPers != nil {
+			min, max, err = config.threshPers.get()
+			ok = err == nil
+		}
+		if !ok {
+			err = errNotAvailable()
+			return
+		}
+		err = writeThresholds(min, max, sourceUser)
+		updateStatus()
+	})
+	return err
+}
+
+// conflictsTitle returns a short description of the conflicts for the UI
+func conflictsTitle(found []conflict) string {
+	var tools []string
+	seen := map[string]bool{}
+	for _, c := range found {
+		if !seen[c.tool] {
+			seen[c.tool] = true
+			tools = append(tools, c.tool)
+		}
+	}
+	return localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "ConflictsTitle", Other: "Thresholds also managed by: {{.Tools}}"}, TemplateData: map[string]interface{}{"Tools": strings.Join(tools, ", ")}})
+}
+
+// conflictsDetails lists the conflicts one per line
+func conflictsDetails(found []conflict) string {
+	var lines []string
+	for _, c := range found {
+		lines = append(lines, c.String())
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,133 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConflictConfigs(t *testing.T) {
+	dir := t.TempDir()
+	tlp := filepath.Join(dir, "tlp.conf")
+	if err := os.WriteFile(tlp, []byte("# START_CHARGE_THRESH_BAT0=75\nSTOP_CHARGE_THRESH_BAT0=\"80\"\nTLP_ENABLE=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := tlpThresholds(tlp); len(got) != 1 || got[0] != "STOP_CHARGE_THRESH_BAT0=80" {
+		t.Fatalf("want: [STOP_CHARGE_THRESH_BAT0=80], got: %v", got)
+	}
+
+	if err := os.WriteFile(tlp, []byte("STOP_CHARGE_THRESH_BAT0=80\nSTART_CHARGE_THRESH_BAT0=75\nSTOP_CHARGE_THRESH_BAT1=90\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := "[START_CHARGE_THRESH_BAT0=75 STOP_CHARGE_THRESH_BAT0=80 STOP_CHARGE_THRESH_BAT1=90]"
+	for i := 0; i < 10; i++ {
+		if got := fmt.Sprint(tlpThresholds(tlp)); got != want {
+			t.Fatalf("want: %s, got: %s", want, got)
+		}
+	}
+
+	acf := filepath.Join(dir, "auto-cpufreq.conf")
+	if err := os.WriteFile(acf, []byte("[battery]\nenable_thresholds = true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !autoCpufreqThresholds(acf) {
+		t.Fatal("auto-cpufreq thresholds not detected")
+	}
+	if autoCpufreqThresholds(filepath.Join(dir, "missing.conf")) {
+		t.Fatal("thresholds detected in missing file")
+	}
+
+	wants := filepath.Join(dir, "graphical.target.wants")
+	glob := filepath.Join(dir, "*.wants", "power-profiles-daemon.service")
+	bat := filepath.Join(dir, "BAT0")
+	if err := os.Mkdir(bat, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(wants, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if ppdCharging(glob, bat) {
+		t.Fatal("power-profiles-daemon detected when not enabled")
+	}
+	if err := os.WriteFile(filepath.Join(wants, "power-profiles-daemon.service"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if ppdCharging(glob, bat) {
+		t.Fatal("power-profiles-daemon detected when it can't change charging")
+	}
+	if err := os.WriteFile(filepath.Join(bat, "charge_type"), []byte("Fast\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !ppdCharging(glob, bat) {
+		t.Fatal("power-profiles-daemon not detected")
+	}
+}
+
+func TestCheckReverted(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	settings.Conflicts.RevertDelay = 0
+	defer func() { settings = defaultSettings() }()
+	drv := &mockDriver{}
+	config.thresh, config.threshWritable = threshDriver{drv}, true
+	defer func() {
+		config.thresh, config.threshWritable = nil, false
+		conflicts.found, conflicts.reverted, conflicts.applied = nil, nil, false
+	}()
+
+	if err := writeThresholds(40, 70, sourceUser); err != nil {
+		t.Fatal(err)
+	}
+	conflicts.Lock()
+	gen := conflicts.gen
+	conflicts.Unlock()
+	if msg := checkReverted(gen); msg != "" {
+		t.Fatalf("reverted when not: %s", msg)
+	}
+
+	drv.vMin, drv.vMax = 0, 100
+	if msg := checkReverted(gen); msg == "" {
+		t.Fatal("revert not detected")
+	}
+	if found := currentConflicts(); len(found) != 1 {
+		t.Fatalf("want 1 conflict, got: %v", found)
+	}
+
+	settings.Conflicts.TakeOver = true
+	if err := writeThresholds(70, 90, sourceUser); err != nil {
+		t.Fatal(err)
+	}
+	drv.vMin, drv.vMax = 0, 100
+	conflicts.Lock()
+	gen = conflicts.gen
+	conflicts.Unlock()
+	checkReverted(gen)
+	if drv.vMin != 70 || drv.vMax != 90 {
+		t.Fatalf("not taken over, got: %d-%d", drv.vMin, drv.vMax)
+	}
+
+	// taking over only once, not to fight the other tool forever
+	drv.vMin, drv.vMax = 0, 100
+	conflicts.Lock()
+	gen = conflicts.gen
+	conflicts.Unlock()
+	checkReverted(gen)
+	if drv.vMin != 0 || drv.vMax != 100 {
+		t.Fatalf("taken over twice, got: %d-%d", drv.vMin, drv.vMax)
+	}
+}
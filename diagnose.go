@@ -0,0 +1,201 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// The report is meant to be pasted into issues, so it is not localized.
+
+const (
+	kernelVersionPath = "/proc/sys/kernel/osrelease"
+	wmiModulePath     = "/sys/module/huawei_wmi"
+)
+
+// probeResult is what diagnostics can tell about an endpoint
+type probeResult struct {
+	kind     string
+	name     string
+	exists   string
+	readErr  error
+	writeErr error
+	value    string
+	files    []string
+}
+
+// printDiagnostics writes the report on endpoints discovery
+func printDiagnostics(w io.Writer) {
+	fmt.Fprintf(w, "matebook-applet version: %s\n", version)
+	fmt.Fprintf(w, "kernel: %s\n", readOrError(kernelVersionPath))
+	fmt.Fprintf(w, "huawei-wmi module: %s\n", wmiModuleInfo())
+	fmt.Fprintf(w, "TLP: %s\n", installed("tlp"))
+	fmt.Fprintf(w, "user: uid %d, groups %s\n", os.Getuid(), groupList())
+	if policy.active() {
+		fmt.Fprintf(w, "policy: %s\n", policyPath)
+	}
+	fmt.Fprintln(w)
+
+	var results []probeResult
+	for _, ep := range fnlockEndpoints {
+		r := newProbe("fnlock", ep)
+		var state bool
+		state, r.readErr = ep.get()
+		r.value = onOff(state)
+		r.writeErr = ep.writable()
+		results = append(results, r)
+	}
+	for _, ep := range threshEndpoints {
+		results = append(results, probeThresh("thresholds", ep))
+	}
+	for _, ep := range threshSaveEndpoints {
+		results = append(results, probeThresh("persistence", ep))
+	}
+	for _, ep := range kdblightTimeoutEndpoints {
+		r := newProbe("kbdlight_timeout", ep)
+		var timeout int
+		timeout, r.readErr = ep.get()
+		r.value = strconv.Itoa(timeout)
+		r.writeErr = ep.writable()
+		results = append(results, r)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TYPE\tENDPOINT\tEXISTS\tREADABLE\tWRITABLE\tVALUE\tERROR")
+	for _, r := range results {
+		value := r.value
+		if r.readErr != nil {
+			value = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.kind, r.name, r.exists, yesNo(r.readErr), yesNo(r.writeErr), value, firstError(r.readErr, r.writeErr))
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tOWNERSHIP\tRAW VALUE")
+	for _, r := range results {
+		for _, f := range r.files {
+			fi, err := os.Stat(f)
+			if err != nil {
+				continue
+			}
+			raw := strings.TrimSpace(readOrError(f))
+			fmt.Fprintf(tw, "%s\t%s\t%q\n", f, describeOwnership(fi), raw)
+		}
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	found := detectConflicts()
+	if len(found) == 0 {
+		fmt.Fprintln(w, "conflicting tools: none found")
+		return
+	}
+	fmt.Fprintln(w, "conflicting tools:")
+	for _, c := range found {
+		fmt.Fprintf(w, "  %s\n", c)
+	}
+}
+
+func probeThresh(kind string, ep threshEndpoint) probeResult {
+	r := newProbe(kind, ep)
+	var min, max int
+	min, max, r.readErr = ep.get()
+	r.value = fmt.Sprintf("%d-%d", min, max)
+	r.writeErr = ep.writable()
+	return r
+}
+
+// newProbe fills in the endpoint name and whether the files the endpoint
+// is backed by (if any) exist
+func newProbe(kind string, ep interface{}) probeResult {
+	r := probeResult{kind: kind, name: fmt.Sprint(ep), exists: "-"}
+	f, ok := ep.(interface{ files() []string })
+	if !ok {
+		return r
+	}
+	r.files = f.files()
+	r.exists = "yes"
+	for _, file := range r.files {
+		if _, err := os.Stat(file); err != nil {
+			r.exists = "no"
+		}
+	}
+	return r
+}
+
+func wmiModuleInfo() string {
+	if _, err := os.Stat(wmiModulePath); err != nil {
+		return "not loaded"
+	}
+	if ver, err := readSysfsString(wmiModulePath + "/version"); err == nil {
+		return "loaded, version " + ver
+	}
+	if out, err := exec.Command("modinfo", "-F", "version", "huawei_wmi").Output(); err == nil && len(strings.TrimSpace(string(out))) > 0 {
+		return "loaded, version " + strings.TrimSpace(string(out))
+	}
+	return "loaded, version unknown"
+}
+
+func installed(name string) string {
+	if path, err := exec.LookPath(name); err == nil {
+		return "installed (" + path + ")"
+	}
+	return "not installed"
+}
+
+func groupList() string {
+	groups, err := os.Getgroups()
+	if err != nil {
+		return err.Error()
+	}
+	var names []string
+	for _, g := range groups {
+		names = append(names, strconv.Itoa(g))
+	}
+	return strings.Join(names, ",")
+}
+
+func readOrError(path string) string {
+	s, err := readSysfsString(path)
+	if err != nil {
+		return err.Error()
+	}
+	return s
+}
+
+func yesNo(err error) string {
+	if err == nil {
+		return "yes"
+	}
+	return "no"
+}
+
+func firstError(errs ...error) string {
+	for _, err := range errs {
+		if err != nil {
+			return err.Error()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,37 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPrintDiagnostics(t *testing.T) {
+	saved := threshEndpoints
+	defer func() { threshEndpoints = saved }()
+	threshEndpoints = []threshEndpoint{threshDriver{&mockDriver{40, 70}}}
+
+	var b bytes.Buffer
+	printDiagnostics(&b)
+	out := b.String()
+	for _, want := range []string{"matebook-applet version", "TYPE", "thresholds", "40-70"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("%q missing from report:\n%s", want, out)
+		}
+	}
+}
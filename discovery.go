@@ -0,0 +1,183 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"syscall"
+	"time"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	defaultDiscoveryInterval = 30 * time.Second
+
+	// sysfs files may show up a bit later than the uevent
+	ueventSettleDelay = time.Second
+)
+
+// ueventKeywords are what makes a uevent worth re-probing the endpoints
+var ueventKeywords = [][]byte{[]byte("huawei"), []byte("power_supply")}
+
+// discoveryRequests asks the discovery loop to re-probe the endpoints
+var discoveryRequests = make(chan struct{}, 1)
+
+// endpointsState is what the UI depends on when deciding what to show
+type endpointsState struct {
+	fnlock, thresh, kdblightTimeout                         string
+	fnlockWritable, threshWritable, kdblightTimeoutWritable bool
+}
+
+func currentEndpoints() endpointsState {
+	return endpointsState{
+		fnlock:                  endpointName(config.fnlock),
+		thresh:                  endpointName(config.thresh),
+		kdblightTimeout:         endpointName(config.kdblightTimeout),
+		fnlockWritable:          config.fnlockWritable,
+		threshWritable:          config.threshWritable,
+		kdblightTimeoutWritable: config.kdblightTimeoutWritable,
+	}
+}
+
+func endpointName(ep interface{}) string {
+	if ep == nil {
+		return ""
+	}
+	return fmt.Sprint(ep)
+}
+
+// nothingFound tells whether there are no endpoints to work with
+func nothingFound() bool {
+	s := cachedStatus()
+	return !s.thresh && !s.fnlock && !s.kdblightTimeout
+}
+
+// rediscover re-probes the endpoints that are missing or no longer work,
+// returning true if anything the UI depends on has changed; it must only be
+// run in the hardware goroutine
+func rediscover() bool {
+	old := currentEndpoints()
+
+	if config.fnlock == nil {
+		findFnlock()
+	} else if _, err := config.fnlock.get(); err != nil {
+		findFnlock()
+	}
+	if config.thresh == nil {
+		findThresh()
+	} else if _, _, err := config.thresh.get(); err != nil {
+		findThresh()
+	}
+	if config.kdblightTimeout == nil {
+		findKdblightTimeout()
+	} else if _, err := config.kdblightTimeout.get(); err != nil {
+		findKdblightTimeout()
+	}
+	findPersistence()
+
+	now := currentEndpoints()
+	if (old.fnlock == "" && now.fnlock != "") || (old.kdblightTimeout == "" && now.kdblightTimeout != "") {
+		if !noSaveValues {
+			applySaved()
+		}
+		applyPolicy()
+	}
+	return now != old
+}
+
+// startDiscovery re-probes the endpoints periodically and on uevents, so
+// that a driver loaded (or reloaded) after the applet has started is used
+func startDiscovery() {
+	go listenUevents()
+
+	interval := settings.DiscoveryInterval
+	if interval > 0 {
+		logTrace.Println("will re-probe the endpoints every", interval)
+		go func() {
+			for range time.Tick(interval) {
+				requestDiscovery()
+			}
+		}()
+	}
+
+	go func() {
+		for range discoveryRequests {
+			var changed bool
+			hwDo(func() {
+				if changed = rediscover(); changed {
+					updateStatus()
+				}
+			})
+			conflictsChanged := updateConflicts()
+			if changed {
+				logInfo.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "EndpointsChanged", Other: "Available settings have changed, updating the interface"}}))
+			}
+			if changed || conflictsChanged {
+				stateChanged()
+			}
+		}
+	}()
+}
+
+func requestDiscovery() {
+	select {
+	case discoveryRequests <- struct{}{}:
+	default:
+	}
+}
+
+// listenUevents requests discovery on kernel uevents concerning the driver
+// or the battery
+func listenUevents() {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM, syscall.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		logTrace.Println("can't listen to uevents:", err)
+		return
+	}
+	defer syscall.Close(fd)
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: 1}); err != nil {
+		logTrace.Println("can't listen to uevents:", err)
+		return
+	}
+
+	buf := make([]byte, 8192)
+	for {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+			logTrace.Println("stopped listening to uevents:", err)
+			return
+		}
+		if !relevantUevent(buf[:n]) {
+			continue
+		}
+		logTrace.Printf("got uevent %q", bytes.SplitN(buf[:n], []byte{0}, 2)[0])
+		time.AfterFunc(ueventSettleDelay, requestDiscovery)
+	}
+}
+
+func relevantUevent(msg []byte) bool {
+	for _, kw := range ueventKeywords {
+		if bytes.Contains(msg, kw) {
+			return true
+		}
+	}
+	return false
+}
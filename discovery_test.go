@@ -0,0 +1,82 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// hotplugFnlock is an endpoint that works only while present
+type hotplugFnlock struct {
+	mockFnlock
+	present *bool
+}
+
+func (h *hotplugFnlock) get() (bool, error) {
+	if !*h.present {
+		return false, errors.New("no such file")
+	}
+	return h.mockFnlock.get()
+}
+
+func TestRediscover(t *testing.T) {
+	savedFnlock, savedThresh, savedKbd := fnlockEndpoints, threshEndpoints, kdblightTimeoutEndpoints
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	defer func() {
+		fnlockEndpoints, threshEndpoints, kdblightTimeoutEndpoints = savedFnlock, savedThresh, savedKbd
+		config.fnlock, config.fnlockWritable = nil, false
+		config.threshPers, config.fnlockPers, config.kdblightTimeoutPers = nil, nil, nil
+	}()
+	threshEndpoints, kdblightTimeoutEndpoints = nil, nil
+	config.thresh, config.kdblightTimeout = nil, nil
+
+	present := false
+	fnlockEndpoints = []fnlockEndpoint{&hotplugFnlock{present: &present}}
+	findFnlock()
+	if config.fnlock != nil {
+		t.Fatal("endpoint found while not present")
+	}
+	if rediscover() {
+		t.Fatal("change reported while nothing has changed")
+	}
+
+	present = true
+	if !rediscover() {
+		t.Fatal("no change reported when endpoint appeared")
+	}
+	if config.fnlock == nil || !config.fnlockWritable {
+		t.Fatal("appeared endpoint not used")
+	}
+
+	present = false
+	if !rediscover() || config.fnlock != nil {
+		t.Fatal("endpoint that went away still used")
+	}
+}
+
+func TestRelevantUevent(t *testing.T) {
+	tests := map[string]bool{
+		"add@/module/huawei_wmi\x00ACTION=add":                           true,
+		"change@/devices/LNXSYSTM:00/power_supply/BAT0\x00ACTION=change": true,
+		"add@/devices/virtual/net/veth0\x00ACTION=add":                   false,
+	}
+	for msg, want := range tests {
+		if got := relevantUevent([]byte(msg)); got != want {
+			t.Errorf("%q: want %v, got %v", msg, want, got)
+		}
+	}
+}
@@ -16,10 +16,9 @@
 package main
 
 import (
-	"bytes"
 	"errors"
+	"fmt"
 	"os"
-	"os/exec"
 	"regexp"
 	"strconv"
 	"strings"
@@ -35,6 +34,9 @@ const (
 	threshKernelMin               = "/charge_control_start_threshold"
 	threshKernelMax               = "/charge_control_end_threshold"
 	saveValuesPath                = "/etc/default/huawei-wmi/"
+	defaultVerifyTimeout          = 2 * time.Second
+	waitVerifyTimeout             = 5 * time.Second
+	verifyPollInterval            = 300 * time.Millisecond
 )
 
 var (
@@ -61,37 +63,18 @@ func init() {
 		max := threshKernelPath + strconv.Itoa(i) + threshKernelMax
 		threshEndpoints = append(threshEndpoints, threshDriver{threshDriverMinMax{pathMin: min, pathMax: max}})
 	}
-
-	if config.useScripts {
-		sudo := "/usr/bin/sudo"
-		fnscr := fnlockScript{toggleCmd: exec.Command(sudo, "-n", "fnlock", "toggle"), getCmd: exec.Command(sudo, "-n", "fnlock", "status")}
-		fnlockEndpoints = append(fnlockEndpoints, fnscr)
-
-		cmdLine := []string{sudo, "-n", "batpro"}
-		batpro := threshScript{
-			getCmd: exec.Command(sudo, append(cmdLine, "status")...),
-			setCmd: exec.Command(sudo, append(cmdLine, "custom")...),
-			offCmd: exec.Command(sudo, append(cmdLine, "off")...),
-		}
-		threshEndpoints = append(threshEndpoints, batpro)
-	}
 }
 
 type fnlockEndpoint interface {
-	toggle()
+	toggle() error
 	get() (bool, error)
-	isWritable() bool
+	writable() error
 }
 
 type threshEndpoint interface {
-	set(min, max int)
+	set(min, max int) error
 	get() (min, max int, err error)
-	isWritable() bool
-}
-
-type fnlockScript struct {
-	getCmd    *exec.Cmd
-	toggleCmd *exec.Cmd
+	writable() error
 }
 
 type fnlockDriver struct {
@@ -101,6 +84,7 @@ type fnlockDriver struct {
 type wmiDriver interface {
 	write(min, max int) error
 	get() (min, max int, err error)
+	writable() error
 }
 
 type threshDriver struct {
@@ -116,25 +100,24 @@ type threshDriverMinMax struct {
 	pathMax string
 }
 
-type threshScript struct {
-	getCmd *exec.Cmd
-	setCmd *exec.Cmd
-	offCmd *exec.Cmd
-}
-
 type kdblightTimeoutEndpoint interface {
-	set(int)
+	set(int) error
 	get() (int, error)
-	isWritable() bool
+	writable() error
 }
 
 type kdblightTimeoutDriver struct {
 	path string
 }
 
-func (drv kdblightTimeoutDriver) set(i int) {
+func (drv kdblightTimeoutDriver) set(i int) error {
 	if i < 0 {
-		return
+		return errors.New(localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "NegativeKdblightTimeout",
+				Other: "keyboard light timeout can not be negative",
+			},
+		}))
 	}
 	err := os.WriteFile(drv.path, []byte(strconv.Itoa(i)), 0664)
 	if err != nil {
@@ -145,6 +128,7 @@ func (drv kdblightTimeoutDriver) set(i int) {
 			},
 		}))
 	}
+	return err
 }
 
 func (drv kdblightTimeoutDriver) get() (int, error) {
@@ -171,23 +155,16 @@ func (drv kdblightTimeoutDriver) get() (int, error) {
 	return result, err
 }
 
-func (drv kdblightTimeoutDriver) isWritable() bool {
-	val, err := drv.get()
-	if err == nil {
-		err = os.WriteFile(drv.path, []byte(strconv.Itoa(val)), 0664)
-		if err == nil {
-			logTrace.Println("successful write to driver interface")
-			return true
-		}
-	}
-	logTrace.Println(err)
-	logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{
-		DefaultMessage: &i18n.Message{
-			ID:    "ReadOnlyDriver",
-			Other: "Driver interface is readable but not writeable.",
-		},
-	}))
-	return false
+func (drv kdblightTimeoutDriver) writable() error {
+	return checkWriteAccess(drv.path)
+}
+
+func (drv kdblightTimeoutDriver) String() string {
+	return drv.path
+}
+
+func (drv kdblightTimeoutDriver) files() []string {
+	return []string{drv.path}
 }
 
 func (drv fnlockDriver) get() (bool, error) {
@@ -225,75 +202,68 @@ func btobb(b bool) []byte {
 	return []byte(s)
 }
 
-func (drv fnlockDriver) checkWritable() bool {
-	val, err := drv.get()
-	if err == nil {
-		err = os.WriteFile(drv.path, btobb(val), 0664)
-		if err == nil {
-			logTrace.Println("successful write to driver interface")
-			return true
-		}
-	}
-	logTrace.Println(err)
-	logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "ReadOnlyDriver", Other: "Driver interface is readable but not writeable."}}))
-	return false
-}
-
-func (drv fnlockDriver) toggle() {
+func (drv fnlockDriver) toggle() error {
 	val, err := drv.get()
 	if err != nil {
 		logError.Println(localizer.MustLocalize(&i18n.LocalizeConfig{MessageID: "CantReadFnlock"}))
 		logTrace.Println(err)
-		return
+		return err
 	}
 	value := btobb(!val)
 	err = os.WriteFile(drv.path, value, 0644)
 	if err != nil {
 		logTrace.Println(err)
 		logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantSetFnlockDriver", Other: "Could not set Fn-Lock status through driver interface"}}))
-		return
+		return err
 	}
 	logTrace.Println("successful write to driver interface")
+	return nil
 }
 
-func (scr threshScript) isWritable() bool {
-	return true
+func (drv threshDriver) String() string {
+	return fmt.Sprint(drv.wmiDriver)
 }
 
-func (drv threshDriver) isWritable() bool {
-	logTrace.Println("Checking if the threshold endpoint is writable...")
-	return drv.checkWritable()
+func (drv threshDriver) files() []string {
+	if f, ok := drv.wmiDriver.(interface{ files() []string }); ok {
+		return f.files()
+	}
+	return nil
 }
 
-func (drv fnlockDriver) isWritable() bool {
-	logTrace.Println("Checking if the fnlock endpoint is writable...")
-	return drv.checkWritable()
+func (drv fnlockDriver) writable() error {
+	return checkWriteAccess(drv.path)
 }
 
-func (scr fnlockScript) isWritable() bool {
-	return true
+func (drv fnlockDriver) String() string {
+	return drv.path
 }
 
-func (scr fnlockScript) get() (bool, error) {
-	cmd := scr.getCmd
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	err := cmd.Run()
-	if err != nil {
-		logError.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantReadFnlockScript", Other: "Failed to get fnlock status from script"}}))
-	}
-	state := parseOnOffStatus(out.String())
-	if state == "on" {
-		return true, err
-	}
-	return false, err
+func (drv fnlockDriver) files() []string {
+	return []string{drv.path}
 }
 
-func (scr fnlockScript) toggle() {
-	cmd := scr.toggleCmd
-	if err := cmd.Run(); err != nil {
-		logError.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantToggleFnlock", Other: "Failed to toggle Fn-Lock"}}))
+// isWritable tells whether the endpoint can be written to, logging the
+// reason if it can't
+func isWritable(ep interface{ writable() error }) bool {
+	logTrace.Printf("Checking if %v is writable...", ep)
+	return reportWritable(ep.writable())
+}
+
+// errNotAvailable is returned when the endpoint has gone away
+func errNotAvailable() error {
+	return errors.New(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "NotAvailable", Other: "The setting is not available at the moment"}}))
+}
+
+// reportWritable logs the result of writability check
+func reportWritable(err error) bool {
+	if err == nil {
+		logTrace.Println("endpoint is writable")
+		return true
 	}
+	logTrace.Println(err)
+	logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "ReadOnlyDriver", Other: "Driver interface is readable but not writeable."}}))
+	return false
 }
 
 func (drv threshDriverSingle) get() (min, max int, err error) {
@@ -400,77 +370,301 @@ func (drv threshDriverSingle) write(min, max int) error {
 	return err
 }
 
-func (drv threshDriver) checkWritable() bool {
-	min, max, err := drv.get()
-	if err != nil {
-		return false
+func (drv threshDriverSingle) writable() error {
+	return checkWriteAccess(drv.path)
+}
+
+func (drv threshDriverMinMax) writable() error {
+	if err := checkWriteAccess(drv.pathMin); err != nil {
+		return err
 	}
-	err = drv.write(min, max)
-	return (err == nil)
+	return checkWriteAccess(drv.pathMax)
 }
 
-func (drv threshDriver) set(min, max int) {
-	if err := drv.write(min, max); err != nil {
-		return
+func (drv threshDriverSingle) String() string {
+	return drv.path
+}
+
+func (drv threshDriverSingle) files() []string {
+	return []string{drv.path}
+}
+
+func (drv threshDriverMinMax) String() string {
+	return drv.pathMin + ", " + drv.pathMax
+}
+
+func (drv threshDriverMinMax) files() []string {
+	return []string{drv.pathMin, drv.pathMax}
+}
+
+func (drv threshDriver) set(min, max int) error {
+	return setVerified(drv.write, drv.get, min, max)
+}
+
+// setVerified writes the thresholds, reads them back to make sure they are
+// set and restores the previous values if they are not
+func setVerified(write func(min, max int) error, get func() (min, max int, err error), min, max int) error {
+	oldMin, oldMax, oldErr := get()
+	err := write(min, max)
+	if err == nil {
+		err = waitThresholds(get, min, max)
+	}
+	if err == nil {
+		return nil
 	}
-	if config.wait {
-		logTrace.Println("thresholds pushed to driver, will wait for them to be set")
+	logTrace.Println(err)
+	if oldErr != nil {
+		logTrace.Println("previous thresholds unknown, can't restore them")
+		return err
+	}
+	logTrace.Printf("restoring previous thresholds %d-%d", oldMin, oldMax)
+	if rerr := write(oldMin, oldMax); rerr != nil {
+		logError.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantRestoreBattery", Other: "Failed to restore previous thresholds"}}))
+		logTrace.Println(rerr)
+	}
+	return err
+}
+
+// waitThresholds reads the thresholds back until they are as expected
+// or verification timeout is reached
+func waitThresholds(get func() (min, max int, err error), min, max int) error {
+	timeout := settings.VerifyTimeout
+	if config.wait && timeout < waitVerifyTimeout {
 		// driver takes some time to set values due to ACPI bug
-		for i := 1; i < 5; i++ {
-			time.Sleep(900 * time.Millisecond)
-			logTrace.Println("checking thresholds, attempt", i)
-			newMin, newMax, err := drv.get()
-			if min == newMin && max == newMax && err == nil {
-				logTrace.Println("thresholds set as expected")
-				break
+		timeout = waitVerifyTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		newMin, newMax, err := get()
+		if err == nil && sameThresholds(min, max, newMin, newMax) {
+			logTrace.Println("thresholds set as expected")
+			return nil
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				return err
 			}
-			logTrace.Println("not set yet")
+			return errors.New(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "BatteryMismatch", Other: "thresholds read back ({{.Min}}%-{{.Max}}%) don't match the requested ones"}, TemplateData: map[string]interface{}{"Min": newMin, "Max": newMax}}))
 		}
-		logTrace.Println("alright, going on")
+		logTrace.Println("thresholds not set yet")
+		time.Sleep(verifyPollInterval)
 	}
 }
 
-func (scr threshScript) get() (min, max int, err error) {
-	cmd := scr.getCmd
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	err = cmd.Run()
-	if err != nil {
-		logError.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantReadBatteryScript", Other: "Failed to get battery protection status from script"}}))
-		return
+// preset is a named pair of thresholds
+type preset struct {
+	name     string
+	min, max int
+}
+
+var presets = []preset{
+	{"off", 0, 100},
+	{"travel", 95, 100},
+	{"office", 70, 90},
+	{"home", 40, 70},
+}
+
+// builtinPresets is how many of the presets are not the custom ones
+var builtinPresets = len(presets)
+
+// customPreset is a preset defined in the configuration file
+type customPreset struct {
+	Name string `toml:"name"`
+	Min  int    `toml:"min"`
+	Max  int    `toml:"max"`
+}
+
+// parseCustomPresets returns the presets defined in the configuration
+// file that can be used, and the errors for the rest of them
+func parseCustomPresets(custom []customPreset) ([]preset, []error) {
+	var valid []preset
+	var errs []error
+	taken := map[string]bool{"custom": true}
+	for _, p := range presets[:builtinPresets] {
+		taken[p.name] = true
 	}
-	state, min, max := parseStatus(out.String())
-	if state == "on" {
-		min = 0
-		max = 100
+	for _, c := range custom {
+		name := strings.ToLower(strings.TrimSpace(c.Name))
+		switch {
+		case name == "" || taken[name]:
+			errs = append(errs, fmt.Errorf("invalid or duplicate preset name %q", c.Name))
+		case c.Min < 0 || c.Max > 100 || c.Min > c.Max:
+			errs = append(errs, fmt.Errorf("invalid thresholds %d-%d of preset %q", c.Min, c.Max, c.Name))
+		default:
+			taken[name] = true
+			valid = append(valid, preset{name, c.Min, c.Max})
+		}
 	}
-	return
+	return valid, errs
 }
 
-func (scr threshScript) set(min, max int) {
-	var cmd *exec.Cmd
-	if min == 0 && max == 100 {
-		cmd = scr.offCmd
-	} else {
-		cmd = scr.setCmd
-		cmd.Args = append(cmd.Args, strconv.Itoa(min), strconv.Itoa(max))
+// addCustomPresets makes the presets defined in the configuration file
+// available along with the built-in ones, skipping the invalid ones
+func addCustomPresets(custom []customPreset) {
+	valid, errs := parseCustomPresets(custom)
+	for _, err := range errs {
+		logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "InvalidPreset", Other: "Preset from the configuration file ignored: {{.Error}}"}, TemplateData: map[string]interface{}{"Error": err}}))
 	}
-	if err := cmd.Run(); err != nil {
-		logError.Println(localizer.MustLocalize(&i18n.LocalizeConfig{MessageID: "CantSetBattery"}))
+	presets = append(presets[:builtinPresets:builtinPresets], valid...)
+}
+
+// customPresets returns the presets defined in the configuration file
+func customPresets() []preset {
+	return presets[builtinPresets:]
+}
+
+// presetTitle returns the title for the UI element that applies a custom
+// preset
+func presetTitle(p preset) string {
+	return fmt.Sprintf("%s (%d%%-%d%%)", strings.ToUpper(p.name), p.min, p.max)
+}
+
+func findPreset(name string) (preset, bool) {
+	for _, p := range presets {
+		if strings.EqualFold(p.name, name) {
+			return p, true
+		}
 	}
+	return preset{}, false
 }
 
-func setThresholds(min int, max int) {
-	config.thresh.set(min, max)
+// setThresholds sets the thresholds and saves them for persistence
+func setThresholds(min int, max int, src changeSource) error {
+	var err error
+	hwDo(func() {
+		err = writeThresholds(min, max, src)
+		updateStatus()
+	})
+	return err
+}
+
+func writeThresholds(min int, max int, src changeSource) (err error) {
+	if config.thresh == nil {
+		return errNotAvailable()
+	}
+	oldMin, oldMax, oldErr := config.thresh.get()
+	defer func() {
+		audit(src, auditThresholds, auditValue(formatThresholds(oldMin, oldMax), oldErr), formatThresholds(min, max), err)
+	}()
+	if err := policy.checkThresholds(min, max); err != nil {
+		logWarning.Println(err)
+		return err
+	}
+	if err := config.thresh.set(min, max); err != nil {
+		err = errors.New(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantSetBatteryReason", Other: "Failed to set thresholds: {{.Error}}"}, TemplateData: map[string]interface{}{"Error": err}}))
+		logError.Println(err)
+		sendNotification(eventFailure, err.Error())
+		return err
+	}
 	if config.threshPers != nil {
 		logTrace.Println("Saving values for persistence...")
-		config.threshPers.set(min, max)
+		if err := config.threshPers.set(min, max); err != nil {
+			logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantSaveBattery", Other: "Failed to save thresholds for persistence"}}))
+			logTrace.Println(err)
+		}
+	}
+	rememberThresholds(min, max)
+	thresholdsApplied(min, max)
+	thresholdsChanged(oldMin, oldMax, min, max)
+	if !sameThresholds(oldMin, oldMax, min, max) {
+		pushUndo(src, undoEntry{setting: auditThresholds, endpoint: fmt.Sprint(config.thresh), min: oldMin, max: oldMax}, oldErr == nil)
+	}
+	return nil
+}
+
+// toggleFnlock toggles Fn-Lock and makes sure it's toggled
+func toggleFnlock(src changeSource) error {
+	var err error
+	hwDo(func() {
+		err = writeFnlock(src)
+		updateStatus()
+	})
+	return err
+}
+
+func writeFnlock(src changeSource) (err error) {
+	if config.fnlock == nil {
+		return errNotAvailable()
 	}
+	old, oldErr := config.fnlock.get()
+	defer func() {
+		audit(src, auditFnlock, auditValue(onOff(old), oldErr), auditValue(onOff(!old), oldErr), err)
+	}()
+	if err := policy.checkFnlock(!old); err != nil {
+		logWarning.Println(err)
+		return err
+	}
+	err = config.fnlock.toggle()
+	if err == nil {
+		var new bool
+		if new, err = config.fnlock.get(); err == nil && new == old {
+			err = errors.New(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "FnlockMismatch", Other: "Fn-Lock state has not changed"}}))
+		}
+		if err == nil {
+			rememberFnlock(new)
+			saveFnlock(new)
+			fnlockChanged(old, new)
+			pushUndo(src, undoEntry{setting: auditFnlock, endpoint: fmt.Sprint(config.fnlock), fnlock: old}, oldErr == nil)
+			return nil
+		}
+	}
+	err = errors.New(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantToggleFnlockReason", Other: "Failed to toggle Fn-Lock: {{.Error}}"}, TemplateData: map[string]interface{}{"Error": err}}))
+	logError.Println(err)
+	sendNotification(eventFailure, err.Error())
+	return err
 }
 
+// setKbdlightTimeout sets keyboard light timeout and makes sure it's set
+func setKbdlightTimeout(timeout int, src changeSource) error {
+	var err error
+	hwDo(func() {
+		err = writeKbdlightTimeout(timeout, src)
+		updateStatus()
+	})
+	return err
+}
+
+func writeKbdlightTimeout(timeout int, src changeSource) (err error) {
+	if config.kdblightTimeout == nil {
+		return errNotAvailable()
+	}
+	old, oldErr := config.kdblightTimeout.get()
+	defer func() {
+		audit(src, auditKbdlightTimeout, auditValue(strconv.Itoa(old), oldErr), strconv.Itoa(timeout), err)
+	}()
+	if err := policy.checkKbdlightTimeout(timeout); err != nil {
+		logWarning.Println(err)
+		return err
+	}
+	err = config.kdblightTimeout.set(timeout)
+	if err == nil {
+		var new int
+		if new, err = config.kdblightTimeout.get(); err == nil && new != timeout {
+			err = errors.New(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "KdblightTimeoutMismatch", Other: "keyboard light timeout read back ({{.Timeout}}) doesn't match the requested one"}, TemplateData: map[string]interface{}{"Timeout": new}}))
+		}
+		if err == nil {
+			saveKbdlightTimeout(new)
+			kbdlightTimeoutChanged(old, new)
+			if old != new {
+				pushUndo(src, undoEntry{setting: auditKbdlightTimeout, endpoint: fmt.Sprint(config.kdblightTimeout), timeout: old}, oldErr == nil)
+			}
+			return nil
+		}
+	}
+	err = errors.New(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantSetKdblightTimeoutReason", Other: "Failed to set keyboard light timeout: {{.Error}}"}, TemplateData: map[string]interface{}{"Error": err}}))
+	logError.Println(err)
+	sendNotification(eventFailure, err.Error())
+	return err
+}
+
+// getStatus describes the cached thresholds state
 func getStatus() string {
 	var r, status string
-	min, max, err := config.thresh.get()
+	s := cachedStatus()
+	if !s.thresh {
+		return localizer.MustLocalize(&i18n.LocalizeConfig{MessageID: "BatteryProtectionStatusError"})
+	}
+	min, max, err := s.min, s.max, s.threshErr
 	if err != nil {
 		r = localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "BatteryProtectionStatusError", Other: "ERROR: can not get BP status!"}})
 	} else {
@@ -488,6 +682,12 @@ func getStatus() string {
 				status = localizer.MustLocalize(&i18n.LocalizeConfig{MessageID: "StatusTravel"})
 			default:
 				status = localizer.MustLocalize(&i18n.LocalizeConfig{MessageID: "StatusCustom", TemplateData: map[string]interface{}{"Min": min, "Max": max}})
+				for _, p := range customPresets() {
+					if p.min == min && p.max == max {
+						status = strings.ToUpper(p.name)
+						break
+					}
+				}
 			}
 		} else {
 			logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "StrangeThresholds", Other: "BP thresholds don't make sense: min {{.Min}}%, max {{.Max}}%"}, TemplateData: map[string]interface{}{"Min": min, "Max": max}}))
@@ -500,9 +700,14 @@ func getStatus() string {
 	return r
 }
 
+// getFnlockStatus describes the cached Fn-Lock state
 func getFnlockStatus() string {
 	var r, status string
-	state, err := config.fnlock.get()
+	s := cachedStatus()
+	if !s.fnlock {
+		return localizer.MustLocalize(&i18n.LocalizeConfig{MessageID: "FnlockStatusError"})
+	}
+	state, err := s.fnlockState, s.fnlockErr
 	if state {
 		status = localizer.MustLocalize(&i18n.LocalizeConfig{MessageID: "StatusOn"})
 	} else {
@@ -516,9 +721,14 @@ func getFnlockStatus() string {
 	return r
 }
 
+// getKbdlightTimeoutStatus describes the cached keyboard light timeout
 func getKbdlightTimeoutStatus() string {
 	var r string
-	timeout, err := config.kdblightTimeout.get()
+	s := cachedStatus()
+	if !s.kdblightTimeout {
+		return localizer.MustLocalize(&i18n.LocalizeConfig{MessageID: "KdblightTimeoutStatusError"})
+	}
+	timeout, err := s.timeout, s.timeoutErr
 	if err != nil {
 		r = localizer.MustLocalize(&i18n.LocalizeConfig{
 			DefaultMessage: &i18n.Message{
@@ -16,11 +16,21 @@
 package main
 
 import (
+	"errors"
+	"io"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/nicksnyder/go-i18n/v2/i18n"
 )
 
+func TestMain(m *testing.M) {
+	logInit(io.Discard, io.Discard, io.Discard, io.Discard)
+	localizer = i18n.NewLocalizer(i18nPrepare(), "en-US")
+	os.Exit(m.Run())
+}
+
 func TestParseStatus(t *testing.T) {
 	type testval struct {
 		status string
@@ -93,6 +103,7 @@ func TestGetStatus(t *testing.T) {
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			config.thresh.set(tc.min, tc.max)
+			refreshStatus()
 			got := getStatus()
 
 			if got != tc.want {
@@ -115,3 +126,128 @@ func (drv *mockDriver) write(min, max int) error {
 	drv.vMax = max
 	return nil
 }
+
+func (drv *mockDriver) writable() error {
+	return nil
+}
+
+func TestSetVerified(t *testing.T) {
+	settings.VerifyTimeout = 10 * time.Millisecond
+	defer func() { settings.VerifyTimeout = defaultVerifyTimeout }()
+
+	tests := map[string]struct {
+		drv      *flakyDriver
+		wantErr  bool
+		min, max int
+	}{
+		"ok": {
+			drv: &flakyDriver{mockDriver: mockDriver{40, 70}},
+			min: 70,
+			max: 90,
+		},
+		"write fails": {
+			drv:     &flakyDriver{mockDriver: mockDriver{40, 70}, failWrite: 1},
+			wantErr: true,
+			min:     40,
+			max:     70,
+		},
+		"value ignored": {
+			drv:     &flakyDriver{mockDriver: mockDriver{40, 70}, ignoreWrite: 1},
+			wantErr: true,
+			min:     40,
+			max:     70,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := threshDriver{tc.drv}.set(70, 90)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("want error: %v, got: %v", tc.wantErr, err)
+			}
+			if tc.drv.vMin != tc.min || tc.drv.vMax != tc.max {
+				t.Fatalf("want: %d-%d, got: %d-%d", tc.min, tc.max, tc.drv.vMin, tc.drv.vMax)
+			}
+		})
+	}
+}
+
+// flakyDriver fails the first write(s) or silently ignores them
+type flakyDriver struct {
+	mockDriver
+	failWrite, ignoreWrite int
+}
+
+func (drv *flakyDriver) write(min, max int) error {
+	if drv.failWrite > 0 {
+		drv.failWrite--
+		drv.vMin, drv.vMax = 0, 100
+		return errors.New("write failed")
+	}
+	if drv.ignoreWrite > 0 {
+		drv.ignoreWrite--
+		return nil
+	}
+	return drv.mockDriver.write(min, max)
+}
+
+func TestToggleFnlock(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	appNotifier = &mockNotifier{}
+	defer func() { config.fnlock = nil }()
+
+	config.fnlock = &mockFnlock{}
+	if err := toggleFnlock(sourceUser); err != nil {
+		t.Fatal(err)
+	}
+
+	config.fnlock = &mockFnlock{stuck: true}
+	if err := toggleFnlock(sourceUser); err == nil {
+		t.Fatal("no error when Fn-Lock didn't toggle")
+	}
+}
+
+type mockFnlock struct {
+	state, stuck bool
+}
+
+func (m *mockFnlock) toggle() error {
+	if !m.stuck {
+		m.state = !m.state
+	}
+	return nil
+}
+
+func (m *mockFnlock) get() (bool, error) {
+	return m.state, nil
+}
+
+func (m *mockFnlock) writable() error {
+	return nil
+}
+
+func TestCustomPresets(t *testing.T) {
+	defer addCustomPresets(nil)
+	addCustomPresets([]customPreset{
+		{Name: "Work", Min: 60, Max: 80},
+		{Name: "work", Min: 50, Max: 80},
+		{Name: "home", Min: 50, Max: 80},
+		{Name: "custom", Min: 50, Max: 80},
+		{Name: "", Min: 50, Max: 80},
+		{Name: "broken", Min: 80, Max: 50},
+	})
+	if got := customPresets(); len(got) != 1 || got[0] != (preset{"work", 60, 80}) {
+		t.Fatalf("want only work preset, got: %v", got)
+	}
+	if p, ok := findPreset("WORK"); !ok || p.min != 60 || p.max != 80 {
+		t.Fatalf("custom preset not found: %v", p)
+	}
+	if got, want := thresholdsName(60, 80), "WORK (60%-80%)"; got != want {
+		t.Fatalf("want: %q, got: %q", want, got)
+	}
+
+	addCustomPresets(nil)
+	if _, ok := findPreset("work"); ok || len(presets) != builtinPresets {
+		t.Fatalf("custom presets not removed: %v", presets)
+	}
+}
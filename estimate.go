@@ -0,0 +1,133 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	estimateInterval = 30 * time.Second
+
+	// the weight of the new charging rate reading
+	estimateSmoothing = 0.3
+)
+
+// chargeEstimator smooths the charging rate readings, which jump a lot
+type chargeEstimator struct {
+	sync.Mutex
+	reading batteryReading
+	rate    float64
+	ok      bool
+}
+
+var estimator chargeEstimator
+
+// add takes a new reading into account, starting anew when the battery
+// starts or stops charging
+func (e *chargeEstimator) add(r batteryReading) {
+	e.Lock()
+	defer e.Unlock()
+	if !e.ok || e.reading.status != r.status || e.rate == 0 {
+		e.rate = float64(r.rate)
+	} else {
+		e.rate = estimateSmoothing*float64(r.rate) + (1-estimateSmoothing)*e.rate
+	}
+	e.reading, e.ok = r, true
+}
+
+func (e *chargeEstimator) get() (batteryReading, float64, bool) {
+	e.Lock()
+	defer e.Unlock()
+	return e.reading, e.rate, e.ok
+}
+
+// watchCharge keeps the charging estimate up to date
+func watchCharge() {
+	var last string
+	for {
+		if r, err := readBattery(); err == nil {
+			estimator.add(r)
+		} else {
+			logTrace.Println(err)
+		}
+		if s := chargeEstimate(); s != last {
+			last = s
+			stateChanged()
+		}
+		time.Sleep(estimateInterval)
+	}
+}
+
+// chargeEstimate describes when charging will stop, or returns an empty
+// string if there's nothing to tell
+func chargeEstimate() string {
+	r, rate, ok := estimator.get()
+	s := cachedStatus()
+	if !ok || !s.thresh || s.threshErr != nil {
+		return ""
+	}
+	return describeCharge(r, rate, s.min, s.max)
+}
+
+func describeCharge(r batteryReading, rate float64, min, max int) string {
+	off := min == 0 && (max == 0 || max == 100)
+	target := max
+	if off {
+		target = 100
+	}
+	switch r.status {
+	case "Charging":
+		need := float64(r.full)*float64(target)/100 - float64(r.now)
+		if rate <= 0 || need <= 0 || r.full <= 0 {
+			return localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "ChargingTo", Other: "Charging to {{.Target}}%"}, TemplateData: map[string]interface{}{"Target": target}})
+		}
+		left := time.Duration(need / rate * float64(time.Hour))
+		return localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "ChargingToLeft", Other: "Charging to {{.Target}}%, about {{.Left}} left"}, TemplateData: map[string]interface{}{"Target": target, "Left": formatLeft(left)}})
+	case "Not charging":
+		if off {
+			return ""
+		}
+		return localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "HoldingAt", Other: "Holding at {{.Capacity}}% (charging paused by threshold)"}, TemplateData: map[string]interface{}{"Capacity": r.capacity}})
+	}
+	return ""
+}
+
+// formatLeft formats the time left, rounded to minutes
+func formatLeft(d time.Duration) string {
+	m := int(d.Round(time.Minute).Minutes())
+	if m < 1 {
+		m = 1
+	}
+	if m < 60 {
+		return localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "LeftMinutes", Other: "{{.Minutes}} min"}, TemplateData: map[string]interface{}{"Minutes": m}})
+	}
+	return localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "LeftHours", Other: "{{.Hours}} h {{.Minutes}} min"}, TemplateData: map[string]interface{}{"Hours": m / 60, "Minutes": fmt.Sprintf("%02d", m%60)}})
+}
+
+// statusWithEstimate returns the battery protection status followed by
+// the charging estimate (if any)
+func statusWithEstimate() string {
+	status := getStatus()
+	if e := chargeEstimate(); e != "" {
+		return status + " · " + e
+	}
+	return status
+}
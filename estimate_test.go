@@ -0,0 +1,58 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestDescribeCharge(t *testing.T) {
+	charging := batteryReading{batteryInfo: batteryInfo{capacity: 50, status: "Charging"}, now: 25000000, full: 50000000}
+	holding := batteryReading{batteryInfo: batteryInfo{capacity: 70, status: "Not charging"}}
+	tests := []struct {
+		r        batteryReading
+		rate     float64
+		min, max int
+		want     string
+	}{
+		{charging, 20000000, 70, 90, "Charging to 90%, about 1 h 00 min left"},
+		{charging, 40000000, 0, 100, "Charging to 100%, about 38 min left"},
+		{charging, 0, 70, 90, "Charging to 90%"},
+		{holding, 0, 40, 70, "Holding at 70% (charging paused by threshold)"},
+		{holding, 0, 0, 100, ""},
+	}
+	for _, tc := range tests {
+		if got := describeCharge(tc.r, tc.rate, tc.min, tc.max); got != tc.want {
+			t.Errorf("want: %q, got: %q", tc.want, got)
+		}
+	}
+}
+
+func TestChargeEstimator(t *testing.T) {
+	var e chargeEstimator
+	r := batteryReading{batteryInfo: batteryInfo{status: "Charging"}, rate: 10}
+	e.add(r)
+	r.rate = 20
+	e.add(r)
+	if _, rate, _ := e.get(); rate != 13 {
+		t.Errorf("want: 13, got: %v", rate)
+	}
+	r.status, r.rate = "Discharging", 5
+	e.add(r)
+	if _, rate, _ := e.get(); rate != 5 {
+		t.Errorf("not reset on status change, got: %v", rate)
+	}
+}
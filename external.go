@@ -0,0 +1,221 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	extVerbGet = "get"
+	extVerbSet = "set"
+
+	capThresholds      = "thresholds"
+	capFnlock          = "fnlock"
+	capKbdlightTimeout = "kbdlight_timeout"
+)
+
+// externalSettings define a vendor tool to be used as an endpoint
+type externalSettings struct {
+	Command cmdTemplate   `toml:"command"`
+	Timeout time.Duration `toml:"timeout"`
+}
+
+// extState is what the external command reports on "get" and is fed on
+// "set" (with only the values to be set present)
+type extState struct {
+	Capabilities    []string       `json:"capabilities,omitempty"`
+	Writable        []string       `json:"writable,omitempty"`
+	Thresholds      *extThreshVals `json:"thresholds,omitempty"`
+	Fnlock          *bool          `json:"fnlock,omitempty"`
+	KbdlightTimeout *int           `json:"kbdlight_timeout,omitempty"`
+	Error           string         `json:"error,omitempty"`
+}
+
+type extThreshVals struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// extCommand is an executable that answers "get" and "set" verbs with JSON
+type extCommand struct {
+	cmd cmdTemplate
+}
+
+type extThresh struct {
+	extCommand
+}
+
+type extFnlock struct {
+	extCommand
+}
+
+type extKbdlightTimeout struct {
+	extCommand
+}
+
+// addExternalEndpoints makes the configured external command the
+// preferred endpoint for whatever it is capable of
+func addExternalEndpoints() {
+	if settings.External.Command == "" {
+		return
+	}
+	ext := extCommand{cmd: settings.External.Command}
+	state, err := ext.query()
+	if err != nil {
+		logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantQueryExternal", Other: "External command is configured but doesn't work"}}))
+		logTrace.Println(err)
+		return
+	}
+	for _, c := range state.Capabilities {
+		switch c {
+		case capThresholds:
+			threshEndpoints = append([]threshEndpoint{extThresh{ext}}, threshEndpoints...)
+		case capFnlock:
+			fnlockEndpoints = append([]fnlockEndpoint{extFnlock{ext}}, fnlockEndpoints...)
+		case capKbdlightTimeout:
+			kdblightTimeoutEndpoints = append([]kdblightTimeoutEndpoint{extKbdlightTimeout{ext}}, kdblightTimeoutEndpoints...)
+		default:
+			logTrace.Printf("external command reports unknown capability %q", c)
+		}
+	}
+}
+
+func (ext extCommand) query() (extState, error) {
+	var state extState
+	out, err := ext.cmd.runWith(settings.External.Timeout, nil, nil, extVerbGet)
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(out, &state); err != nil {
+		return state, fmt.Errorf("can't make sense of %q: %w", out, err)
+	}
+	if state.Error != "" {
+		return state, errors.New(state.Error)
+	}
+	return state, nil
+}
+
+func (ext extCommand) apply(req extState) error {
+	in, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	out, err := ext.cmd.runWith(settings.External.Timeout, nil, in, extVerbSet)
+	if err != nil {
+		return err
+	}
+	var reply extState
+	if len(out) > 0 {
+		if err := json.Unmarshal(out, &reply); err != nil {
+			return fmt.Errorf("can't make sense of %q: %w", out, err)
+		}
+	}
+	if reply.Error != "" {
+		return errors.New(reply.Error)
+	}
+	return nil
+}
+
+func (ext extCommand) String() string {
+	return string(ext.cmd)
+}
+
+// canSet tells whether the external command reports the capability writable
+func (ext extCommand) canSet(capability string) error {
+	state, err := ext.query()
+	if err != nil {
+		return err
+	}
+	for _, w := range state.Writable {
+		if w == capability {
+			return nil
+		}
+	}
+	return fmt.Errorf("external command can't set %s", capability)
+}
+
+func (ext extThresh) get() (min, max int, err error) {
+	state, err := ext.query()
+	if err != nil {
+		return
+	}
+	if state.Thresholds == nil {
+		err = errors.New("external command reported no thresholds")
+		return
+	}
+	return state.Thresholds.Min, state.Thresholds.Max, nil
+}
+
+func (ext extThresh) set(min, max int) error {
+	return setVerified(ext.write, ext.get, min, max)
+}
+
+func (ext extThresh) write(min, max int) error {
+	return ext.apply(extState{Thresholds: &extThreshVals{Min: min, Max: max}})
+}
+
+func (ext extThresh) writable() error {
+	return ext.canSet(capThresholds)
+}
+
+func (ext extFnlock) get() (bool, error) {
+	state, err := ext.query()
+	if err != nil {
+		return false, err
+	}
+	if state.Fnlock == nil {
+		return false, errors.New("external command reported no Fn-Lock state")
+	}
+	return *state.Fnlock, nil
+}
+
+func (ext extFnlock) toggle() error {
+	state, err := ext.get()
+	if err != nil {
+		return err
+	}
+	state = !state
+	return ext.apply(extState{Fnlock: &state})
+}
+
+func (ext extFnlock) writable() error {
+	return ext.canSet(capFnlock)
+}
+
+func (ext extKbdlightTimeout) get() (int, error) {
+	state, err := ext.query()
+	if err != nil {
+		return 0, err
+	}
+	if state.KbdlightTimeout == nil {
+		return 0, errors.New("external command reported no keyboard light timeout")
+	}
+	return *state.KbdlightTimeout, nil
+}
+
+func (ext extKbdlightTimeout) set(timeout int) error {
+	return ext.apply(extState{KbdlightTimeout: &timeout})
+}
+
+func (ext extKbdlightTimeout) writable() error {
+	return ext.canSet(capKbdlightTimeout)
+}
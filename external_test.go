@@ -0,0 +1,71 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeTool reports the state it has in state.json and saves the request
+// it gets on set to request.json
+const fakeTool = `#!/bin/sh
+dir="$(dirname "$0")"
+case "$1" in
+get) cat "$dir/state.json" ;;
+set) cat > "$dir/request.json"; echo '{}' ;;
+*) echo '{"error": "unknown verb"}'; exit 1 ;;
+esac
+`
+
+func TestExtCommand(t *testing.T) {
+	dir := t.TempDir()
+	tool := filepath.Join(dir, "tool")
+	if err := os.WriteFile(tool, []byte(fakeTool), 0755); err != nil {
+		t.Fatal(err)
+	}
+	state := `{"capabilities": ["thresholds", "fnlock"], "writable": ["fnlock"], "thresholds": {"min": 40, "max": 70}, "fnlock": true}`
+	if err := os.WriteFile(filepath.Join(dir, "state.json"), []byte(state), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ext := extCommand{cmd: cmdTemplate(tool)}
+
+	min, max, err := extThresh{ext}.get()
+	if err != nil || min != 40 || max != 70 {
+		t.Fatalf("want: 40-70, got: %d-%d, %v", min, max, err)
+	}
+	if (extThresh{ext}).writable() == nil {
+		t.Error("thresholds are reported writable")
+	}
+	if err := (extFnlock{ext}).writable(); err != nil {
+		t.Error(err)
+	}
+	if _, err := (extKbdlightTimeout{ext}).get(); err == nil {
+		t.Error("no error for missing keyboard light timeout")
+	}
+
+	if err := (extFnlock{ext}).toggle(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "request.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"fnlock":false}`; string(b) != want {
+		t.Fatalf("want: %s, got: %s", want, b)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"sync"
+)
+
+// All the hardware access (and the changes of config endpoints) happens in
+// a single goroutine, so that the requests coming from the tray, the window
+// and the other instances never interleave. The UI shows the state cached
+// after the last request instead of reading the hardware.
+
+var (
+	hwQueue = make(chan func())
+	hwOnce  sync.Once
+
+	status      hwStatus
+	statusMutex sync.RWMutex
+)
+
+// hwStatus is the last known state of the endpoints
+type hwStatus struct {
+	thresh, threshWritable                   bool
+	min, max                                 int
+	threshErr                                error
+	fnlock, fnlockWritable                   bool
+	fnlockState                              bool
+	fnlockErr                                error
+	kdblightTimeout, kdblightTimeoutWritable bool
+	timeout                                  int
+	timeoutErr                               error
+}
+
+// hwDo runs f in the hardware goroutine and waits for it to finish, f must
+// not call hwDo itself
+func hwDo(f func()) {
+	hwOnce.Do(func() {
+		go func() {
+			for req := range hwQueue {
+				req()
+			}
+		}()
+	})
+	done := make(chan struct{})
+	hwQueue <- func() {
+		defer close(done)
+		f()
+	}
+	<-done
+}
+
+// refreshStatus reads the state of the endpoints into the cache
+func refreshStatus() {
+	hwDo(updateStatus)
+}
+
+// cachedStatus returns the state of the endpoints as of the last request
+func cachedStatus() hwStatus {
+	statusMutex.RLock()
+	defer statusMutex.RUnlock()
+	return status
+}
+
+// updateStatus reads the state of the endpoints into the cache, it must
+// only be run in the hardware goroutine
+func updateStatus() {
+	var s hwStatus
+	if config.thresh != nil {
+		s.thresh, s.threshWritable = true, config.threshWritable
+		s.min, s.max, s.threshErr = config.thresh.get()
+	}
+	if config.fnlock != nil {
+		s.fnlock, s.fnlockWritable = true, config.fnlockWritable
+		s.fnlockState, s.fnlockErr = config.fnlock.get()
+	}
+	if config.kdblightTimeout != nil {
+		s.kdblightTimeout, s.kdblightTimeoutWritable = true, config.kdblightTimeoutWritable
+		s.timeout, s.timeoutErr = config.kdblightTimeout.get()
+	}
+	statusMutex.Lock()
+	status = s
+	statusMutex.Unlock()
+}
@@ -0,0 +1,64 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowDriver takes its time to write and counts the writes in progress
+type slowDriver struct {
+	mockDriver
+	busy, overlaps int32
+}
+
+func (drv *slowDriver) write(min, max int) error {
+	if atomic.AddInt32(&drv.busy, 1) > 1 {
+		atomic.AddInt32(&drv.overlaps, 1)
+	}
+	defer atomic.AddInt32(&drv.busy, -1)
+	time.Sleep(time.Millisecond)
+	return drv.mockDriver.write(min, max)
+}
+
+func TestHardwareSerialized(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	drv := &slowDriver{}
+	config.thresh = threshDriver{drv}
+	config.threshWritable = true
+	defer func() { config.thresh, config.threshWritable = nil, false }()
+
+	var wg sync.WaitGroup
+	for _, p := range presets {
+		wg.Add(1)
+		go func(p preset) {
+			defer wg.Done()
+			setThresholds(p.min, p.max, sourceUser)
+		}(p)
+	}
+	wg.Wait()
+
+	if drv.overlaps != 0 {
+		t.Fatalf("%d writes overlapped", drv.overlaps)
+	}
+	s := cachedStatus()
+	if !s.thresh || s.min != drv.vMin || s.max != drv.vMax {
+		t.Fatalf("cache is stale: %d-%d, driver has %d-%d", s.min, s.max, drv.vMin, drv.vMax)
+	}
+}
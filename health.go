@@ -0,0 +1,149 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	defaultHealthFullDays = 3
+	defaultHealthPreset   = "home"
+	healthFullLevel       = 95
+	healthStateFile       = "health.json"
+
+	// samples further apart mean the laptop was off or asleep, which
+	// doesn't count as being kept on AC
+	healthMaxGap = 3 * usageSampleInterval
+)
+
+// healthSettings define when to warn about the battery kept at full charge
+type healthSettings struct {
+	FullDays int    `toml:"full_days"`
+	Preset   string `toml:"preset"`
+}
+
+// healthState is the warning the user has dismissed, identified by when
+// the battery started to be kept at full
+type healthState struct {
+	DismissedRun time.Time `json:"dismissed_run"`
+}
+
+var healthWarning struct {
+	sync.Mutex
+	since time.Time
+}
+
+// fullSince returns when the laptop started to be kept on AC with the
+// battery charged over healthFullLevel without a break, or zero time if it
+// isn't now
+func fullSince(samples []usageSample, now time.Time) time.Time {
+	var since time.Time
+	next := now
+	for i := len(samples) - 1; i >= 0; i-- {
+		s := samples[i]
+		if !s.AC || s.Capacity <= healthFullLevel || next.Sub(s.Time) > healthMaxGap {
+			break
+		}
+		since, next = s.Time, s.Time
+	}
+	return since
+}
+
+// updateHealth checks whether the battery has been kept at full for too
+// long
+func updateHealth(samples []usageSample, now time.Time) {
+	var since time.Time
+	days := settings.Health.FullDays
+	if full := fullSince(samples, now); days > 0 && !full.IsZero() && now.Sub(full) >= time.Duration(days)*24*time.Hour {
+		since = full
+	}
+
+	healthWarning.Lock()
+	changed := !since.Equal(healthWarning.since)
+	healthWarning.since = since
+	healthWarning.Unlock()
+	if !changed {
+		return
+	}
+	if since, ok := currentHealthWarning(); ok {
+		msg := healthTitle(since)
+		logWarning.Println(msg)
+		sendNotification(eventHealth, msg)
+	}
+	stateChanged()
+}
+
+// currentHealthWarning returns when the battery started to be kept at
+// full, unless the thresholds don't let it, the user has dismissed the
+// warning or can't do anything about it
+func currentHealthWarning() (time.Time, bool) {
+	healthWarning.Lock()
+	since := healthWarning.since
+	healthWarning.Unlock()
+	if since.IsZero() || policy.checkPreset(healthPreset()) != nil {
+		return since, false
+	}
+	s := cachedStatus()
+	if !s.thresh || s.threshErr != nil || (s.max < healthFullLevel && s.max != 0) {
+		return since, false
+	}
+	var st healthState
+	if _, err := readState(healthStateFile, &st); err == nil && st.DismissedRun.Equal(since) {
+		return since, false
+	}
+	// it's intended
+	if topUpInProgress() != nil || calibrationInProgress() != nil {
+		return since, false
+	}
+	return since, true
+}
+
+func healthPreset() string {
+	if settings.Health.Preset == "" {
+		return defaultHealthPreset
+	}
+	return settings.Health.Preset
+}
+
+// healthTitle returns the title for the UI element that applies the
+// healthier preset
+func healthTitle(since time.Time) string {
+	return localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "HealthWarning", Other: "Battery kept over {{.Level}}% on AC for {{.Days}} days, click to switch to {{.Preset}}"}, TemplateData: map[string]interface{}{"Level": healthFullLevel, "Days": int(time.Since(since).Hours() / 24), "Preset": strings.ToUpper(healthPreset())}})
+}
+
+// applyHealthPreset switches to the preset that is better for the battery
+func applyHealthPreset() error {
+	return applyPreset(healthPreset(), sourceUser)
+}
+
+// dismissHealthWarning hides the warning until the battery is kept at
+// full the next time
+func dismissHealthWarning() error {
+	healthWarning.Lock()
+	since := healthWarning.since
+	healthWarning.Unlock()
+	if err := writeState(healthStateFile, healthState{DismissedRun: since}); err != nil {
+		return err
+	}
+	logTrace.Println("health warning dismissed")
+	stateChanged()
+	return nil
+}
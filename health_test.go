@@ -0,0 +1,70 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHealthWarning(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	settings.Notifications.Health = false
+	defer func() { settings = defaultSettings() }()
+	config.thresh = threshDriver{&mockDriver{0, 100}}
+	refreshStatus()
+	defer func() {
+		config.thresh = nil
+		refreshStatus()
+		healthWarning.since = time.Time{}
+	}()
+
+	now := time.Now()
+	var samples []usageSample
+	perDay := int(24 * time.Hour / usageSampleInterval)
+	for i := 4 * perDay; i > 0; i-- {
+		samples = append(samples, usageSample{Time: now.Add(-time.Duration(i) * usageSampleInterval), AC: true, Capacity: 100})
+	}
+	samples[10].AC = false
+
+	if since := fullSince(samples, now); !since.Equal(samples[11].Time) {
+		t.Fatalf("want: %v, got: %v", samples[11].Time, since)
+	}
+	// the laptop was asleep for a while
+	gap := append(append([]usageSample{}, samples[:100]...), samples[110:]...)
+	if since := fullSince(gap, now); !since.Equal(samples[110].Time) {
+		t.Fatalf("after a gap want: %v, got: %v", samples[110].Time, since)
+	}
+	if since := fullSince(samples, now.Add(time.Hour)); !since.IsZero() {
+		t.Fatalf("no recent samples, got: %v", since)
+	}
+
+	updateHealth(samples, now)
+	if _, warn := currentHealthWarning(); !warn {
+		t.Fatal("no warning after 3 days at full")
+	}
+	if err := dismissHealthWarning(); err != nil {
+		t.Fatal(err)
+	}
+	if _, warn := currentHealthWarning(); warn {
+		t.Fatal("dismissed warning still shown")
+	}
+
+	updateHealth(samples[len(samples)-perDay:], now)
+	if _, warn := currentHealthWarning(); warn {
+		t.Fatal("warning after a day at full")
+	}
+}
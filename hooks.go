@@ -0,0 +1,124 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	defaultHookTimeout = 10 * time.Second
+	hookShell          = "/bin/sh"
+)
+
+// hookSettings are the user commands to run when the state changes
+type hookSettings struct {
+	Timeout                  time.Duration `toml:"timeout"`
+	OnThresholdsChanged      string        `toml:"on_thresholds_changed"`
+	OnFnlockChanged          string        `toml:"on_fnlock_changed"`
+	OnKbdlightTimeoutChanged string        `toml:"on_kbdlight_timeout_changed"`
+}
+
+func thresholdsChanged(oldMin, oldMax, newMin, newMax int) {
+	if oldMin == newMin && oldMax == newMax {
+		return
+	}
+	go runHook("on_thresholds_changed", settings.Hooks.OnThresholdsChanged, map[string]string{
+		"MATEBOOK_OLD_MIN": strconv.Itoa(oldMin),
+		"MATEBOOK_OLD_MAX": strconv.Itoa(oldMax),
+		"MATEBOOK_NEW_MIN": strconv.Itoa(newMin),
+		"MATEBOOK_NEW_MAX": strconv.Itoa(newMax),
+	})
+}
+
+func fnlockChanged(old, new bool) {
+	if old == new {
+		return
+	}
+	go runHook("on_fnlock_changed", settings.Hooks.OnFnlockChanged, map[string]string{
+		"MATEBOOK_OLD_FNLOCK": onOff(old),
+		"MATEBOOK_NEW_FNLOCK": onOff(new),
+	})
+}
+
+func kbdlightTimeoutChanged(old, new int) {
+	if old == new {
+		return
+	}
+	go runHook("on_kbdlight_timeout_changed", settings.Hooks.OnKbdlightTimeoutChanged, map[string]string{
+		"MATEBOOK_OLD_KBDLIGHT_TIMEOUT": strconv.Itoa(old),
+		"MATEBOOK_NEW_KBDLIGHT_TIMEOUT": strconv.Itoa(new),
+	})
+}
+
+// runHook executes the user command with the values passed in environment
+func runHook(name, command string, env map[string]string) {
+	if command == "" {
+		return
+	}
+	timeout := settings.Hooks.Timeout
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+	cmd := exec.Command(hookShell, "-c", command)
+	cmd.Env = append(os.Environ(), "MATEBOOK_EVENT="+name)
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	// hook gets its own process group so that whatever it spawns is killed
+	// on timeout, too
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	logTrace.Printf("running %s hook: %q", name, command)
+	err := cmd.Start()
+	if err == nil {
+		killed := make(chan struct{})
+		timer := time.AfterFunc(timeout, func() {
+			defer close(killed)
+			logTrace.Printf("%s hook timed out, killing it", name)
+			_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		})
+		err = cmd.Wait()
+		if !timer.Stop() {
+			// don't leave the killer running behind our back
+			<-killed
+		}
+	}
+	if out.Len() > 0 {
+		logTrace.Printf("%s hook output:\n%s", name, out.String())
+	}
+	if err != nil {
+		logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "HookFailed", Other: "Hook {{.Hook}} failed"}, TemplateData: map[string]interface{}{"Hook": name}}))
+		logTrace.Println(err)
+	}
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
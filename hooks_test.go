@@ -0,0 +1,59 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+func TestRunHook(t *testing.T) {
+	logInit(io.Discard, io.Discard, io.Discard, io.Discard)
+	localizer = i18n.NewLocalizer(i18nPrepare(), "en-US")
+	out := filepath.Join(t.TempDir(), "out")
+
+	runHook("on_test", `echo "$MATEBOOK_EVENT $MATEBOOK_OLD_MIN $MATEBOOK_NEW_MIN" > `+out, map[string]string{
+		"MATEBOOK_OLD_MIN": "40",
+		"MATEBOOK_NEW_MIN": "70",
+	})
+
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "on_test 40 70\n"
+	if string(b) != want {
+		t.Fatalf("want: %q, got: %q", want, b)
+	}
+}
+
+func TestRunHookTimeout(t *testing.T) {
+	logInit(io.Discard, io.Discard, io.Discard, io.Discard)
+	localizer = i18n.NewLocalizer(i18nPrepare(), "en-US")
+	settings.Hooks.Timeout = 100 * time.Millisecond
+	defer func() { settings.Hooks.Timeout = defaultHookTimeout }()
+
+	start := time.Now()
+	runHook("on_test", "sleep 5", nil)
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("hook was not killed after timeout, took %v", d)
+	}
+}
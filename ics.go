@@ -0,0 +1,181 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// icsEvent is the part of an iCalendar VEVENT the applet cares about
+type icsEvent struct {
+	summary     string
+	description string
+	categories  string
+	start, end  time.Time
+}
+
+// readCalendar reads the events from an .ics file or from all the .ics
+// files in a directory
+func readCalendar(path string) ([]icsEvent, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	if fi.IsDir() {
+		if files, err = filepath.Glob(filepath.Join(path, "*.ics")); err != nil {
+			return nil, err
+		}
+	}
+	var events []icsEvent
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, parseICS(string(b))...)
+	}
+	return events, nil
+}
+
+// parseICS parses the events of an iCalendar file, recurring events are
+// only taken into account once, events with invalid dates are skipped
+func parseICS(s string) []icsEvent {
+	var events []icsEvent
+	var ev *icsEvent
+	var allDay bool
+	// depth of the components (e.g. VALARM) nested in the event, their
+	// properties are not the event's
+	var nested int
+	for _, line := range unfoldICS(s) {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		name, params, _ := strings.Cut(name, ";")
+		name = strings.ToUpper(name)
+		if nested > 0 {
+			switch name {
+			case "BEGIN":
+				nested++
+			case "END":
+				nested--
+			}
+			continue
+		}
+		switch name {
+		case "BEGIN":
+			if ev != nil {
+				nested++
+			} else if strings.EqualFold(value, "VEVENT") {
+				ev, allDay = &icsEvent{}, false
+			}
+		case "END":
+			if ev == nil || !strings.EqualFold(value, "VEVENT") {
+				continue
+			}
+			if ev.end.IsZero() && allDay {
+				ev.end = ev.start.AddDate(0, 0, 1)
+			}
+			if ev.end.Before(ev.start) {
+				ev.end = ev.start
+			}
+			if !ev.start.IsZero() {
+				events = append(events, *ev)
+			}
+			ev = nil
+		case "SUMMARY":
+			if ev != nil {
+				ev.summary = unescapeICS(value)
+			}
+		case "DESCRIPTION":
+			if ev != nil {
+				ev.description = unescapeICS(value)
+			}
+		case "CATEGORIES":
+			if ev != nil {
+				ev.categories = unescapeICS(value)
+			}
+		case "DTSTART":
+			if ev == nil {
+				continue
+			}
+			t, date, err := parseICSTime(value, params)
+			if err != nil {
+				logTrace.Println(err)
+				continue
+			}
+			ev.start, allDay = t, date
+		case "DTEND":
+			if ev == nil {
+				continue
+			}
+			if t, _, err := parseICSTime(value, params); err == nil {
+				ev.end = t
+			} else {
+				logTrace.Println(err)
+			}
+		}
+	}
+	return events
+}
+
+// unfoldICS splits iCalendar contents into lines, joining the folded ones
+func unfoldICS(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func unescapeICS(s string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(s)
+}
+
+// parseICSTime parses DATE or DATE-TIME value, telling whether it was
+// a DATE
+func parseICSTime(value, params string) (time.Time, bool, error) {
+	loc := time.Local
+	for _, p := range strings.Split(params, ";") {
+		k, v, _ := strings.Cut(p, "=")
+		if strings.EqualFold(k, "TZID") {
+			if l, err := time.LoadLocation(strings.Trim(v, `"`)); err == nil {
+				loc = l
+			}
+		}
+	}
+	switch {
+	case len(value) == 8:
+		t, err := time.ParseInLocation("20060102", value, time.Local)
+		return t, true, err
+	case strings.HasSuffix(value, "Z"):
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	case len(value) == 15:
+		t, err := time.ParseInLocation("20060102T150405", value, loc)
+		return t, false, err
+	}
+	return time.Time{}, false, fmt.Errorf("invalid iCalendar date %q", value)
+}
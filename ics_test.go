@@ -0,0 +1,76 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+const testICS = "BEGIN:VCALENDAR\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Flight to \r\n Berlin\r\n" +
+	"DESCRIPTION:Gate B12\r\n" +
+	"CATEGORIES:TRAVEL,WORK\r\n" +
+	"DTSTART:20261020T080000Z\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"ACTION:DISPLAY\r\n" +
+	"SUMMARY:Reminder\r\n" +
+	"DESCRIPTION:Leave for the airport\r\n" +
+	"TRIGGER:-PT3H\r\n" +
+	"END:VALARM\r\n" +
+	"DTEND:20261020T120000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Conference\r\n" +
+	"DTSTART;VALUE=DATE:20261021\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Meeting\r\n" +
+	"DTSTART;TZID=Europe/Berlin:20261022T100000\r\n" +
+	"DTEND;TZID=Europe/Berlin:20261022T110000\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICS(t *testing.T) {
+	events := parseICS(testICS)
+	if len(events) != 3 {
+		t.Fatalf("want 3 events, got: %v", events)
+	}
+
+	ev := events[0]
+	if ev.summary != "Flight to Berlin" || ev.description != "Gate B12" || ev.categories != "TRAVEL,WORK" {
+		t.Errorf("wrong event: %+v", ev)
+	}
+	if want := time.Date(2026, 10, 20, 8, 0, 0, 0, time.UTC); !ev.start.Equal(want) {
+		t.Errorf("want start %v, got %v", want, ev.start)
+	}
+	if want := time.Date(2026, 10, 20, 12, 0, 0, 0, time.UTC); !ev.end.Equal(want) {
+		t.Errorf("want end %v, got %v", want, ev.end)
+	}
+
+	ev = events[1]
+	if want := time.Date(2026, 10, 22, 0, 0, 0, 0, time.Local); !ev.end.Equal(want) {
+		t.Errorf("all-day event: want end %v, got %v", want, ev.end)
+	}
+
+	if loc, err := time.LoadLocation("Europe/Berlin"); err == nil {
+		ev = events[2]
+		if want := time.Date(2026, 10, 22, 10, 0, 0, 0, loc); !ev.start.Equal(want) {
+			t.Errorf("want start %v, got %v", want, ev.start)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	instanceName    = "matebook-applet"
+	cmdPreset       = "preset"
+	cmdShow         = "show"
+	replyOK         = "ok"
+	replyErrPrefix  = "error: "
+	instanceTimeout = 5 * time.Second
+)
+
+var (
+	instanceLock *os.File
+)
+
+// runtimeDir returns the per-user directory for runtime files, falling back
+// to a private directory in the shared temporary one
+func runtimeDir() (string, error) {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return dir, nil
+	}
+	dir := filepath.Join(os.TempDir(), instanceName+"-"+strconv.Itoa(os.Getuid()))
+	if err := os.Mkdir(dir, 0700); err != nil && !errors.Is(err, fs.ErrExist) {
+		return "", err
+	}
+	// anyone could have created it first, so make sure it's really ours
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return "", err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !fi.IsDir() || !ok || int(st.Uid) != os.Getuid() || fi.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("%s is not a private directory", dir)
+	}
+	return dir, nil
+}
+
+// runtimePath returns the path of a per-user runtime file
+func runtimePath(ext string) (string, error) {
+	dir, err := runtimeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, instanceName+ext), nil
+}
+
+// acquireInstanceLock returns false if another instance already holds the lock
+func acquireInstanceLock() bool {
+	path, err := runtimePath(".lock")
+	var f *os.File
+	if err == nil {
+		f, err = os.OpenFile(path, os.O_CREATE|os.O_RDWR|syscall.O_NOFOLLOW, 0600)
+	}
+	if err != nil {
+		logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantLockInstance", Other: "Failed to check for another running instance"}}))
+		logTrace.Println(err)
+		return true
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return false
+		}
+		logTrace.Println(err)
+		return true
+	}
+	instanceLock = f
+	return true
+}
+
+// forwardRequests passes the command line requests to the running instance
+func forwardRequests(preset string, show bool) {
+	var cmds []string
+	if preset != "" {
+		cmds = append(cmds, cmdPreset+" "+preset)
+	}
+	if show {
+		cmds = append(cmds, cmdShow)
+	}
+	if len(cmds) == 0 {
+		logInfo.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "AlreadyRunning", Other: "Another instance of the applet is already running"}}))
+		return
+	}
+	for _, cmd := range cmds {
+		if err := sendInstanceCommand(cmd); err != nil {
+			logError.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantForward", Other: "Failed to pass {{.Command}} to the running instance: {{.Error}}"}, TemplateData: map[string]interface{}{"Command": cmd, "Error": err}}))
+		}
+	}
+}
+
+func sendInstanceCommand(cmd string) error {
+	path, err := runtimePath(".sock")
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialTimeout("unix", path, instanceTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(instanceTimeout))
+	logTrace.Printf("forwarding %q to the running instance", cmd)
+	if _, err := fmt.Fprintln(conn, cmd); err != nil {
+		return err
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	reply = strings.TrimSpace(reply)
+	if reply != replyOK {
+		return errors.New(strings.TrimPrefix(reply, replyErrPrefix))
+	}
+	return nil
+}
+
+// listenInstanceCommands serves the requests of other invocations
+func listenInstanceCommands() {
+	path, err := runtimePath(".sock")
+	var l net.Listener
+	if err == nil {
+		// we hold the lock, so whatever is there is left from a crashed instance
+		_ = os.Remove(path)
+		l, err = net.Listen("unix", path)
+	}
+	if err != nil {
+		logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantListen", Other: "Failed to set up control socket, requests from other invocations will be ignored"}}))
+		logTrace.Println(err)
+		return
+	}
+	logTrace.Println("listening for commands on", path)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				logTrace.Println(err)
+				return
+			}
+			go serveInstanceConn(conn)
+		}
+	}()
+}
+
+func serveInstanceConn(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(instanceTimeout))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		logTrace.Println(err)
+		return
+	}
+	reply := replyOK
+	if err := handleInstanceCommand(strings.TrimSpace(line)); err != nil {
+		reply = replyErrPrefix + err.Error()
+	}
+	fmt.Fprintln(conn, reply)
+}
+
+func handleInstanceCommand(line string) error {
+	logTrace.Printf("got command %q", line)
+	verb, arg, _ := strings.Cut(line, " ")
+	switch verb {
+	case cmdPreset:
+		return applyPreset(arg, sourceCommand)
+	case cmdShow:
+		showWindow()
+		return nil
+	}
+	return fmt.Errorf("unknown command %q", verb)
+}
+
+// applyPreset sets the thresholds of a preset with the given name
+func applyPreset(name string, src changeSource) error {
+	p, ok := findPreset(name)
+	if !ok {
+		return fmt.Errorf("unknown preset %q", name)
+	}
+	if err := policy.checkPreset(p.name); err != nil {
+		return err
+	}
+	if s := cachedStatus(); !s.thresh || !s.threshWritable {
+		return errors.New("no writable battery thresholds endpoint")
+	}
+	logTrace.Printf("applying preset %s", p.name)
+	err := setThresholds(p.min, p.max, src)
+	stateChanged()
+	return err
+}
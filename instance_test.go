@@ -0,0 +1,96 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestInstance(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	drv := &mockDriver{}
+	config.thresh = threshDriver{drv}
+	config.threshWritable = true
+	refreshStatus()
+
+	if !acquireInstanceLock() {
+		t.Fatal("failed to acquire lock")
+	}
+	defer func() {
+		instanceLock.Close()
+		instanceLock = nil
+	}()
+	if acquireInstanceLock() {
+		t.Fatal("lock acquired twice")
+	}
+
+	listenInstanceCommands()
+
+	if err := sendInstanceCommand("preset office"); err != nil {
+		t.Fatal(err)
+	}
+	if drv.vMin != 70 || drv.vMax != 90 {
+		t.Fatalf("want: 70-90, got: %d-%d", drv.vMin, drv.vMax)
+	}
+
+	if err := sendInstanceCommand("preset nonexistent"); err == nil {
+		t.Fatal("no error for unknown preset")
+	}
+	if err := sendInstanceCommand("dance"); err == nil {
+		t.Fatal("no error for unknown command")
+	}
+}
+
+func TestRuntimeDir(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	t.Setenv("TMPDIR", t.TempDir())
+	want := filepath.Join(os.TempDir(), instanceName+"-"+strconv.Itoa(os.Getuid()))
+
+	dir, err := runtimeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != want {
+		t.Fatalf("want: %s, got: %s", want, dir)
+	}
+	fi, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0700 {
+		t.Fatalf("want: 0700, got: %o", fi.Mode().Perm())
+	}
+
+	if err := os.Chmod(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runtimeDir(); err == nil {
+		t.Fatal("no error for a world-writable directory")
+	}
+
+	if err := os.Remove(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(t.TempDir(), dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runtimeDir(); err == nil {
+		t.Fatal("no error for a symlink")
+	}
+}
@@ -17,6 +17,7 @@ package main
 
 import (
 	"embed"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -39,17 +40,25 @@ var (
 	logError     *log.Logger
 	version      = "custom-build"
 	iconPath     string
+	settingsPath string
+	presetName   string
+	diagnose     bool
 	saveValues   bool
 	noSaveValues bool
 	localizer    *i18n.Localizer
 	config       struct {
-		fnlock          fnlockEndpoint
-		thresh          threshEndpoint
-		threshPers      threshEndpoint
-		kdblightTimeout kdblightTimeoutEndpoint
-		wait            bool
-		useScripts      bool
-		windowed        bool
+		fnlock                  fnlockEndpoint
+		thresh                  threshEndpoint
+		threshPers              threshEndpoint
+		fnlockPers              *savedValue
+		kdblightTimeoutPers     *savedValue
+		kdblightTimeout         kdblightTimeoutEndpoint
+		fnlockWritable          bool
+		threshWritable          bool
+		kdblightTimeoutWritable bool
+		wait                    bool
+		useScripts              bool
+		windowed                bool
 	}
 )
 
@@ -59,9 +68,34 @@ var assets embed.FS
 func main() {
 	i18nInit()
 	parseFlags()
+	loadSettings(settingsPath)
+	addCustomPresets(settings.Presets)
+	loadPolicy(policyPath)
+
+	if cmd := flag.Arg(0); cmd != "" {
+		if err := runCommand(cmd, flag.Args()[1:]); err != nil {
+			logError.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if diagnose {
+		addScriptEndpoints()
+		addExternalEndpoints()
+		printDiagnostics(os.Stdout)
+		return
+	}
+
+	if !acquireInstanceLock() {
+		forwardRequests(presetName, config.windowed)
+		return
+	}
 
 	logInfo.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "AppletVersion", Other: "matebook-applet version {{.Version}}"}, TemplateData: map[string]interface{}{"Version": version}}))
 
+	addScriptEndpoints()
+	addExternalEndpoints()
 	findFnlock()
 	findThresh()
 	findKdblightTimeout()
@@ -70,40 +104,62 @@ func main() {
 		logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "OptionSDeprecated", Other: "-s option is deprecated, applet is now saving values for persistence by default"}}))
 	}
 
-	if !noSaveValues {
-		logTrace.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "LookingForBatteryPers", Other: "looking for endpoint to save thresholds to..."}}))
-		for _, ep := range threshSaveEndpoints {
-			_, _, err := ep.get()
-			if err == nil {
-				logInfo.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "FoundBatteryPers", Other: "Persistence thresholds values endpoint found."}}))
-				config.threshPers = ep
-				break
-			}
+	findPersistence()
+	hwDo(func() {
+		if !noSaveValues {
+			applySaved()
+		}
+		applyPolicy()
+		updateStatus()
+	})
+	checkRestore()
+	updateConflicts()
+
+	if presetName != "" {
+		if err := applyPreset(presetName, sourceCommand); err != nil {
+			logError.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantApplyPreset", Other: "Failed to apply preset: {{.Error}}"}, TemplateData: map[string]interface{}{"Error": err}}))
 		}
 	}
 
-	if config.thresh != nil || config.fnlock != nil {
-		if config.windowed {
-			if err := ui.Main(launchUI); err != nil {
-				logError.Println(err)
-			}
-		} else {
-			systray.Run(onReady, onExit)
+	if nothingFound() {
+		logError.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "NothingToWorkWith", Other: "Neither a supported version of Huawei-WMI driver, nor any of the required scripts are properly installed, see README.md#installation-and-setup for instructions"}}))
+		logInfo.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "WaitingForEndpoints", Other: "Will keep running and start working as soon as the driver shows up"}}))
+	}
+
+	startDiscovery()
+	startMonitor()
+	if settings.RestoreThresholds {
+		go watchResume()
+	}
+	go watchTopUp()
+	go watchCalibration()
+	go watchTravel()
+	go watchUsage()
+	go watchCharge()
+	if config.windowed {
+		if err := ui.Main(func() {
+			launchUI()
+			listenInstanceCommands()
+		}); err != nil {
+			logError.Println(err)
 		}
+		pauseCalibration()
 	} else {
-		logError.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "NothingToWorkWith", Other: "Neither a supported version of Huawei-WMI driver, nor any of the required scripts are properly installed, see README.md#installation-and-setup for instructions"}}))
+		systray.Run(onReady, onExit)
 	}
 }
 
 // findFnlock finds working fnlock interface (if any)
 func findFnlock() {
+	config.fnlock, config.fnlockWritable = nil, false
 	for _, fnlck := range fnlockEndpoints {
 		_, err := fnlck.get()
 		if err != nil {
 			continue
 		}
 		config.fnlock = fnlck
-		if fnlck.isWritable() {
+		config.fnlockWritable = isWritable(fnlck)
+		if config.fnlockWritable {
 			logInfo.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "FoundFnlock", Other: "Found writable fnlock endpoint, will use it"}}))
 			break
 		}
@@ -112,13 +168,15 @@ func findFnlock() {
 
 // findThresh finds working threshold interface (if any)
 func findThresh() {
+	config.thresh, config.threshWritable = nil, false
 	for _, thresh := range threshEndpoints {
 		_, _, err := thresh.get()
 		if err != nil {
 			continue
 		}
 		config.thresh = thresh
-		if thresh.isWritable() {
+		config.threshWritable = isWritable(thresh)
+		if config.threshWritable {
 			logInfo.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "FoundBattery", Other: "Found writable battery thresholds endpoint, will use it"}}))
 			break
 		}
@@ -127,13 +185,15 @@ func findThresh() {
 
 // findKdblightTimeout finds working kdblight_timeout interface (if any)
 func findKdblightTimeout() {
+	config.kdblightTimeout, config.kdblightTimeoutWritable = nil, false
 	for _, kdblightTimeout := range kdblightTimeoutEndpoints {
 		_, err := kdblightTimeout.get()
 		if err != nil {
 			continue
 		}
 		config.kdblightTimeout = kdblightTimeout
-		if kdblightTimeout.isWritable() {
+		config.kdblightTimeoutWritable = isWritable(kdblightTimeout)
+		if config.kdblightTimeoutWritable {
 			logInfo.Println(localizer.MustLocalize(&i18n.LocalizeConfig{
 				DefaultMessage: &i18n.Message{
 					ID:    "FoundKdblightTimeout",
@@ -145,6 +205,31 @@ func findKdblightTimeout() {
 	}
 }
 
+// findThreshPers finds the endpoint to save thresholds to (if any)
+func findThreshPers() {
+	if noSaveValues {
+		return
+	}
+	logTrace.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "LookingForBatteryPers", Other: "looking for endpoint to save thresholds to..."}}))
+	if ep, ok := threshPersTarget(); ok {
+		if err := ep.writable(); err != nil {
+			logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantUsePersistence", Other: "Can't save thresholds to {{.Target}} ({{.Path}}), they won't survive a reboot: {{.Error}}"}, TemplateData: map[string]interface{}{"Target": settings.ThreshPersistence, "Path": ep, "Error": err}}))
+			return
+		}
+		logInfo.Println(localizer.MustLocalize(&i18n.LocalizeConfig{MessageID: "FoundBatteryPers"}))
+		config.threshPers = ep
+		return
+	}
+	for _, ep := range threshSaveEndpoints {
+		_, _, err := ep.get()
+		if err == nil {
+			logInfo.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "FoundBatteryPers", Other: "Persistence thresholds values endpoint found."}}))
+			config.threshPers = ep
+			break
+		}
+	}
+}
+
 func parseFlags() {
 	verbose := flag.Bool("v", false, localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "FlagV", Other: "be verbose"}}))
 	verboseMore := flag.Bool("vv", false, localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "FlagVV", Other: "be very verbose"}}))
@@ -153,16 +238,53 @@ func parseFlags() {
 	flag.BoolVar(&noSaveValues, "n", false, localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "FlagN", Other: "do not save values"}}))
 	flag.BoolVar(&config.useScripts, "r", false, localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "FlagR", Other: "use fnlock and batpro scripts if all else fails"}}))
 	flag.BoolVar(&config.windowed, "w", false, localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "FlagW", Other: "windowed mode"}}))
+	flag.StringVar(&presetName, "preset", "", localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "FlagPreset", Other: "apply battery protection preset (off, travel, office, home or one from the configuration file)"}}))
+	flag.StringVar(&settingsPath, "config", "", localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "FlagConfig", Other: "path of the configuration file to use"}}))
+	flag.BoolVar(&diagnose, "diagnose", false, localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "FlagDiagnose", Other: "print a report on endpoints discovery and exit"}}))
 	flag.Parse()
 
+	// commands print their results to stdout, so nothing else goes there
+	var out io.Writer = os.Stdout
+	if flag.Arg(0) != "" {
+		out = os.Stderr
+	}
 	switch {
 	case *verbose:
-		logInit(io.Discard, os.Stdout, os.Stdout, os.Stderr)
+		logInit(io.Discard, out, out, os.Stderr)
 	case *verboseMore:
-		logInit(os.Stdout, os.Stdout, os.Stdout, os.Stderr)
+		logInit(out, out, out, os.Stderr)
 	default:
-		logInit(io.Discard, io.Discard, os.Stdout, os.Stderr)
+		logInit(io.Discard, io.Discard, out, os.Stderr)
+	}
+
+	if settingsPath == "" {
+		settingsPath = defaultSettingsPath()
+	}
+}
+
+// runCommand runs the command given on the command line instead of the
+// applet
+func runCommand(name string, args []string) error {
+	switch name {
+	case "history":
+		return printHistory(os.Stdout)
+	case "export":
+		return exportProfile(os.Stdout)
+	case "import":
+		fl := flag.NewFlagSet("import", flag.ExitOnError)
+		dryRun := fl.Bool("dry-run", false, localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "FlagDryRun", Other: "only show what would be changed"}}))
+		fl.Parse(args)
+		if fl.NArg() != 1 {
+			return errors.New("usage: matebook-applet import [-dry-run] profile.toml")
+		}
+		// the running instance would keep using the old settings and could
+		// change the same endpoints at the same time
+		if !*dryRun && !acquireInstanceLock() {
+			return errors.New(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "ImportWhileRunning", Other: "Another instance of the applet is running, quit it before importing a profile"}}))
+		}
+		return importProfile(fl.Arg(0), *dryRun, os.Stdout)
 	}
+	return fmt.Errorf("unknown command %q", name)
 }
 
 func logInit(
@@ -192,9 +314,9 @@ func i18nInit() {
 
 	lang, err := jibber_jabber.DetectIETF()
 	if err != nil {
-		fmt.Println("could not detect locale")
+		fmt.Fprintln(os.Stderr, "could not detect locale")
 	}
-	fmt.Println(lang)
+	fmt.Fprintln(os.Stderr, lang)
 
 	localizer = i18n.NewLocalizer(bundle, lang)
 }
@@ -0,0 +1,156 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	defaultPollInterval = time.Minute
+)
+
+// known is the last state the applet has seen or set itself, so that
+// changes made by other means can be told apart
+var known struct {
+	sync.Mutex
+	min, max    int
+	thresh      bool
+	fnlock      bool
+	fnlockKnown bool
+	full        bool
+}
+
+func rememberThresholds(min, max int) {
+	known.Lock()
+	known.min, known.max, known.thresh = min, max, true
+	known.Unlock()
+}
+
+func rememberFnlock(state bool) {
+	known.Lock()
+	known.fnlock, known.fnlockKnown = state, true
+	known.Unlock()
+}
+
+// sameThresholds compares thresholds, taking into account that driver
+// may report 0 0 for BP OFF
+func sameThresholds(aMin, aMax, bMin, bMax int) bool {
+	if aMin == 0 && bMin == 0 && (aMax == 0 || aMax == 100) && (bMax == 0 || bMax == 100) {
+		return true
+	}
+	return aMin == bMin && aMax == bMax
+}
+
+// startMonitor periodically probes the state if the user wants to be
+// notified of something that requires probing
+func startMonitor() {
+	if !settings.Notifications.ExternalChanges && !settings.Notifications.FullCharge {
+		logTrace.Println("nothing to monitor, not probing the state")
+		return
+	}
+	interval := settings.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	logTrace.Println("will probe the state every", interval)
+
+	s := cachedStatus()
+	if s.thresh && s.threshErr == nil {
+		rememberThresholds(s.min, s.max)
+	}
+	if s.fnlock && s.fnlockErr == nil {
+		rememberFnlock(s.fnlockState)
+	}
+
+	go func() {
+		for range time.Tick(interval) {
+			hwDo(func() {
+				if settings.Notifications.ExternalChanges {
+					checkExternalChanges()
+				}
+				if settings.Notifications.FullCharge {
+					checkFullCharge()
+				}
+				updateStatus()
+			})
+			stateChanged()
+		}
+	}()
+}
+
+// checkExternalChanges must only be run in the hardware goroutine
+func checkExternalChanges() {
+	if config.thresh != nil {
+		if min, max, err := config.thresh.get(); err == nil {
+			known.Lock()
+			changed := known.thresh && !sameThresholds(min, max, known.min, known.max)
+			known.Unlock()
+			rememberThresholds(min, max)
+			if changed {
+				logInfo.Printf("thresholds changed externally to %d-%d", min, max)
+				sendNotification(eventExternalChange, localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "NotifyThresholdsChanged", Other: "Battery protection thresholds were changed outside the applet: {{.Min}}%-{{.Max}}%"}, TemplateData: map[string]interface{}{"Min": min, "Max": max}}))
+			}
+		}
+	}
+	if config.fnlock != nil {
+		if state, err := config.fnlock.get(); err == nil {
+			known.Lock()
+			changed := known.fnlockKnown && state != known.fnlock
+			known.Unlock()
+			rememberFnlock(state)
+			if changed {
+				logInfo.Println("Fn-Lock changed externally")
+				sendNotification(eventExternalChange, localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "NotifyFnlockChanged", Other: "Fn-Lock was changed outside the applet"}}))
+			}
+		}
+	}
+}
+
+// checkFullCharge must only be run in the hardware goroutine
+func checkFullCharge() {
+	if config.thresh == nil {
+		return
+	}
+	_, max, err := config.thresh.get()
+	if err != nil {
+		return
+	}
+	if max == 0 {
+		max = 100
+	}
+	bat, err := getBattery()
+	if err != nil {
+		logTrace.Println(err)
+		return
+	}
+
+	known.Lock()
+	defer known.Unlock()
+	if bat.capacity < max || bat.status == "Discharging" {
+		known.full = false
+		return
+	}
+	if known.full {
+		return
+	}
+	known.full = true
+	logInfo.Printf("battery charged to %d%%", bat.capacity)
+	sendNotification(eventFullCharge, localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "NotifyFullCharge", Other: "Battery is charged to {{.Capacity}}%"}, TemplateData: map[string]interface{}{"Capacity": bat.capacity}}))
+}
@@ -0,0 +1,163 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	defaultNotifyInterval = time.Minute
+	notifyTimeout         = 10000 // milliseconds
+	notifyIcon            = "battery"
+
+	// a notification is given up on if the bus doesn't take it in time
+	notifyCallTimeout = 5 * time.Second
+	notifyQueueSize   = 16
+)
+
+type notifyEvent int
+
+const (
+	eventFailure notifyEvent = iota
+	eventExternalChange
+	eventFullCharge
+	eventRestored
+	eventTopUp
+	eventCalibration
+	eventTravel
+	eventHealth
+)
+
+// notificationSettings define which events the user wants to be notified of
+type notificationSettings struct {
+	Failures        bool          `toml:"failures"`
+	ExternalChanges bool          `toml:"external_changes"`
+	FullCharge      bool          `toml:"full_charge"`
+	Restored        bool          `toml:"restored"`
+	TopUp           bool          `toml:"top_up"`
+	Calibration     bool          `toml:"calibration"`
+	Travel          bool          `toml:"travel"`
+	Health          bool          `toml:"health"`
+	MinInterval     time.Duration `toml:"min_interval"`
+}
+
+type notifier interface {
+	notify(summary, body string) error
+}
+
+// gdbusNotifier sends notifications to org.freedesktop.Notifications
+// via gdbus utility, so that we don't need to talk D-Bus ourselves
+type gdbusNotifier struct{}
+
+// notification is a notification waiting to be sent
+type notification struct {
+	n    notifier
+	body string
+}
+
+var (
+	appNotifier  notifier = gdbusNotifier{}
+	lastNotified          = map[notifyEvent]time.Time{}
+	notifyMutex  sync.Mutex
+	notifyQueue  = make(chan notification, notifyQueueSize)
+	notifyOnce   sync.Once
+)
+
+func (gdbusNotifier) notify(summary, body string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyCallTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "gdbus", "call", "--session",
+		"--dest", "org.freedesktop.Notifications",
+		"--object-path", "/org/freedesktop/Notifications",
+		"--method", "org.freedesktop.Notifications.Notify",
+		"matebook-applet", "0", notifyIcon, summary, body, "[]", "{}", strconv.Itoa(notifyTimeout))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		logTrace.Printf("gdbus: %s", out)
+	}
+	return err
+}
+
+func (ev notifyEvent) enabled() bool {
+	switch ev {
+	case eventFailure:
+		return settings.Notifications.Failures
+	case eventExternalChange:
+		return settings.Notifications.ExternalChanges
+	case eventFullCharge:
+		return settings.Notifications.FullCharge
+	case eventRestored:
+		return settings.Notifications.Restored
+	case eventTopUp:
+		return settings.Notifications.TopUp
+	case eventCalibration:
+		return settings.Notifications.Calibration
+	case eventTravel:
+		return settings.Notifications.Travel
+	case eventHealth:
+		return settings.Notifications.Health
+	}
+	return false
+}
+
+// sendNotification shows a desktop notification unless the user doesn't
+// want notifications for this event type or there already was one recently
+func sendNotification(ev notifyEvent, body string) {
+	if !ev.enabled() {
+		return
+	}
+
+	notifyMutex.Lock()
+	interval := settings.Notifications.MinInterval
+	if interval <= 0 {
+		interval = defaultNotifyInterval
+	}
+	if last, ok := lastNotified[ev]; ok && time.Since(last) < interval {
+		notifyMutex.Unlock()
+		logTrace.Println("notification suppressed:", body)
+		return
+	}
+	lastNotified[ev] = time.Now()
+	n := appNotifier
+	notifyMutex.Unlock()
+
+	// the notification is sent from its own goroutine, so that a slow
+	// session bus doesn't hold up the caller (possibly the hardware
+	// goroutine)
+	notifyOnce.Do(func() { go deliverNotifications() })
+	select {
+	case notifyQueue <- notification{n, body}:
+	default:
+		logTrace.Println("notification queue is full, dropped:", body)
+	}
+}
+
+// deliverNotifications sends the queued notifications one by one
+func deliverNotifications() {
+	for nt := range notifyQueue {
+		if err := nt.n.notify("matebook-applet", nt.body); err != nil {
+			logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantNotify", Other: "Failed to show desktop notification"}}))
+			logTrace.Println(err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+type mockNotifier struct {
+	sync.Mutex
+	sent []string
+}
+
+func (n *mockNotifier) notify(summary, body string) error {
+	n.Lock()
+	n.sent = append(n.sent, body)
+	n.Unlock()
+	return nil
+}
+
+// wait returns the notifications sent once there are count of them, or
+// the ones sent so far after a while
+func (n *mockNotifier) wait(count int) []string {
+	for i := 0; i < 100; i++ {
+		n.Lock()
+		sent := append([]string(nil), n.sent...)
+		n.Unlock()
+		if len(sent) >= count {
+			return sent
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	n.Lock()
+	defer n.Unlock()
+	return append([]string(nil), n.sent...)
+}
+
+// stuckNotifier never gets the notification through
+type stuckNotifier chan struct{}
+
+func (n stuckNotifier) notify(summary, body string) error {
+	<-n
+	return nil
+}
+
+func TestSendNotification(t *testing.T) {
+	logInit(io.Discard, io.Discard, io.Discard, io.Discard)
+	localizer = i18n.NewLocalizer(i18nPrepare(), "en-US")
+	n := &mockNotifier{}
+	appNotifier = n
+	lastNotified = map[notifyEvent]time.Time{}
+	settings = defaultSettings()
+	defer func() { settings = defaultSettings() }()
+
+	sendNotification(eventFailure, "one")
+	sendNotification(eventFailure, "two")
+	sendNotification(eventExternalChange, "three")
+
+	settings.Notifications.ExternalChanges = true
+	sendNotification(eventExternalChange, "four")
+
+	settings.Notifications.MinInterval = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	sendNotification(eventFailure, "five")
+
+	want := []string{"one", "four", "five"}
+	sent := n.wait(len(want))
+	if len(sent) != len(want) {
+		t.Fatalf("want: %v, got: %v", want, sent)
+	}
+	for i := range want {
+		if sent[i] != want[i] {
+			t.Fatalf("want: %v, got: %v", want, sent)
+		}
+	}
+}
+
+func TestSendNotificationStuck(t *testing.T) {
+	stuck := make(stuckNotifier)
+	defer close(stuck)
+	appNotifier = stuck
+	lastNotified = map[notifyEvent]time.Time{}
+	settings = defaultSettings()
+	settings.Notifications.MinInterval = time.Nanosecond
+	defer func() {
+		settings = defaultSettings()
+		appNotifier = &mockNotifier{}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 2*notifyQueueSize; i++ {
+			sendNotification(eventFailure, "stuck")
+			time.Sleep(time.Microsecond)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("sending notifications blocks when the bus is stuck")
+	}
+}
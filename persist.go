@@ -0,0 +1,234 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	fnlockSaveFile          = "fn_lock_state"
+	kdblightTimeoutSaveFile = "kbdlight_timeout"
+
+	persistHuaweiWMI = "huawei-wmi"
+	persistTLP       = "tlp"
+
+	tlpDropInPath   = "/etc/tlp.d/50-matebook-applet.conf"
+	defaultBattery  = "BAT0"
+	generatedHeader = "# Generated by matebook-applet, changes will be overwritten\n"
+)
+
+// savedValue is a file a setting is saved to, so that it can be re-applied
+// after reboot; a user file is created when needed, a system one has to
+// exist already
+type savedValue struct {
+	path string
+	user bool
+}
+
+// saveCandidates returns the files to save the setting to in the order of
+// preference: the system one (as used by huawei-wmi tooling), then the one
+// in user's state directory
+func saveCandidates(name string) []savedValue {
+	candidates := []savedValue{{path: saveValuesPath + name}}
+	if dir, err := userStateDir(); err == nil {
+		candidates = append(candidates, savedValue{path: filepath.Join(dir, name), user: true})
+	}
+	return candidates
+}
+
+// findSavedValue returns the file to save the setting to (if any), a system
+// one is only used if the user can write to it
+func findSavedValue(candidates []savedValue) *savedValue {
+	for _, v := range candidates {
+		if v.user || checkWriteAccess(v.path) == nil {
+			logTrace.Printf("will save the setting to %s", v.path)
+			return &v
+		}
+	}
+	return nil
+}
+
+func (v savedValue) get() (string, error) {
+	b, err := os.ReadFile(v.path)
+	return strings.TrimSpace(string(b)), err
+}
+
+func (v savedValue) set(value string) error {
+	if v.user {
+		if err := os.MkdirAll(filepath.Dir(v.path), 0700); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(v.path, []byte(value+"\n"), 0644)
+}
+
+func (v savedValue) String() string {
+	return v.path
+}
+
+// findPersistence finds the endpoints to save the settings to, unless the
+// user doesn't want them saved
+func findPersistence() {
+	if noSaveValues {
+		return
+	}
+	if config.threshPers == nil {
+		findThreshPers()
+	}
+	if config.fnlockPers == nil {
+		config.fnlockPers = findSavedValue(saveCandidates(fnlockSaveFile))
+	}
+	if config.kdblightTimeoutPers == nil {
+		config.kdblightTimeoutPers = findSavedValue(saveCandidates(kdblightTimeoutSaveFile))
+	}
+}
+
+// saveFnlock saves Fn-Lock state for persistence (if there's where to)
+func saveFnlock(state bool) {
+	if config.fnlockPers == nil {
+		return
+	}
+	logTrace.Println("Saving Fn-Lock state for persistence...")
+	if err := config.fnlockPers.set(string(btobb(state))); err != nil {
+		logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantSaveFnlock", Other: "Failed to save Fn-Lock state for persistence"}}))
+		logTrace.Println(err)
+	}
+}
+
+// saveKbdlightTimeout saves keyboard light timeout for persistence (if
+// there's where to)
+func saveKbdlightTimeout(timeout int) {
+	if config.kdblightTimeoutPers == nil {
+		return
+	}
+	logTrace.Println("Saving keyboard light timeout for persistence...")
+	if err := config.kdblightTimeoutPers.set(strconv.Itoa(timeout)); err != nil {
+		logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantSaveKdblightTimeout", Other: "Failed to save keyboard light timeout for persistence"}}))
+		logTrace.Println(err)
+	}
+}
+
+// applySaved re-applies saved Fn-Lock state and keyboard light timeout if
+// they differ from the live ones; it must only be run in the hardware
+// goroutine
+func applySaved() {
+	if config.fnlock != nil && config.fnlockWritable && config.fnlockPers != nil {
+		if saved, err := config.fnlockPers.get(); err == nil && (saved == "0" || saved == "1") {
+			if live, err := config.fnlock.get(); err == nil && live != (saved == "1") {
+				logInfo.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "ApplyingSavedFnlock", Other: "Applying saved Fn-Lock state"}}))
+				writeFnlock(sourceSaved)
+			}
+		}
+	}
+	if config.kdblightTimeout != nil && config.kdblightTimeoutWritable && config.kdblightTimeoutPers != nil {
+		if saved, err := config.kdblightTimeoutPers.get(); err == nil {
+			timeout, err := strconv.Atoi(saved)
+			if err != nil {
+				logTrace.Println(err)
+				return
+			}
+			if live, err := config.kdblightTimeout.get(); err == nil && live != timeout {
+				logInfo.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "ApplyingSavedKdblightTimeout", Other: "Applying saved keyboard light timeout"}}))
+				writeKbdlightTimeout(timeout, sourceSaved)
+			}
+		}
+	}
+}
+
+// threshPersTarget returns the endpoint to save the thresholds to, as chosen
+// by the user
+func threshPersTarget() (threshDriver, bool) {
+	switch settings.ThreshPersistence {
+	case persistTLP:
+		return threshDriver{tlpDropIn{path: tlpDropInPath, battery: batteryName()}}, true
+	case persistHuaweiWMI, "":
+	default:
+		logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "UnknownPersistence", Other: "Unknown thresholds persistence target {{.Target}}, using the default one"}, TemplateData: map[string]interface{}{"Target": settings.ThreshPersistence}}))
+	}
+	return threshDriver{}, false
+}
+
+// tlpDropIn saves the thresholds to TLP configuration, so that TLP applies
+// them instead of reverting them
+type tlpDropIn struct {
+	path    string
+	battery string
+}
+
+func (t tlpDropIn) get() (min, max int, err error) {
+	b, err := os.ReadFile(t.path)
+	if err != nil {
+		return
+	}
+	vars := parseShellVars(b)
+	min, err = strconv.Atoi(vars["START_CHARGE_THRESH_"+t.battery])
+	if err != nil {
+		return
+	}
+	max, err = strconv.Atoi(vars["STOP_CHARGE_THRESH_"+t.battery])
+	return
+}
+
+func (t tlpDropIn) write(min, max int) error {
+	s := fmt.Sprintf("%sSTART_CHARGE_THRESH_%s=%d\nSTOP_CHARGE_THRESH_%s=%d\n", generatedHeader, t.battery, min, t.battery, max)
+	return os.WriteFile(t.path, []byte(s), 0644)
+}
+
+func (t tlpDropIn) writable() error {
+	return checkCreateAccess(t.path)
+}
+
+func (t tlpDropIn) String() string {
+	return t.path
+}
+
+func (t tlpDropIn) files() []string {
+	return []string{t.path}
+}
+
+// parseShellVars reads VAR=value lines of a shell-like configuration file
+// (such as TLP's), ignoring comments
+func parseShellVars(b []byte) map[string]string {
+	vars := map[string]string{}
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		vars[strings.TrimSpace(k)] = strings.Trim(strings.TrimSpace(v), `"'`)
+	}
+	return vars
+}
+
+// batteryName returns the name of the battery as TLP knows it
+func batteryName() string {
+	dir, err := findBattery()
+	if err != nil {
+		return defaultBattery
+	}
+	return filepath.Base(dir)
+}
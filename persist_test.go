@@ -0,0 +1,112 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFindSavedValue(t *testing.T) {
+	dir := t.TempDir()
+	system := savedValue{path: filepath.Join(dir, "system", fnlockSaveFile)}
+	user := savedValue{path: filepath.Join(dir, "user", fnlockSaveFile), user: true}
+
+	v := findSavedValue([]savedValue{system, user})
+	if v == nil || *v != user {
+		t.Fatalf("want: %v, got: %v", user, v)
+	}
+	if err := v.set("1"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := v.get(); err != nil || got != "1" {
+		t.Fatalf("want: 1, got: %q, %v", got, err)
+	}
+
+	if v := findSavedValue([]savedValue{system}); v != nil {
+		t.Fatalf("missing system file used: %v", v)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(system.path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(system.path, []byte("0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if v := findSavedValue([]savedValue{system, user}); v == nil || *v != system {
+		t.Fatalf("want: %v, got: %v", system, v)
+	}
+	if os.Getuid() == 0 {
+		t.Skip("root can write to any file")
+	}
+	if err := os.Chmod(system.path, 0444); err != nil {
+		t.Fatal(err)
+	}
+	if v := findSavedValue([]savedValue{system, user}); v == nil || *v != user {
+		t.Fatalf("read-only system file used: %v", v)
+	}
+}
+
+func TestApplySaved(t *testing.T) {
+	v := &savedValue{path: filepath.Join(t.TempDir(), fnlockSaveFile), user: true}
+	if err := v.set("1"); err != nil {
+		t.Fatal(err)
+	}
+	fnlock := &mockFnlock{}
+	config.fnlock, config.fnlockWritable, config.fnlockPers = fnlock, true, v
+	defer func() { config.fnlock, config.fnlockWritable, config.fnlockPers = nil, false, nil }()
+
+	applySaved()
+	if !fnlock.state {
+		t.Fatal("saved Fn-Lock state not applied")
+	}
+	applySaved()
+	if !fnlock.state {
+		t.Fatal("Fn-Lock toggled when already as saved")
+	}
+}
+
+func TestThreshPersTargets(t *testing.T) {
+	settings.VerifyTimeout = 10 * time.Millisecond
+	defer func() { settings = defaultSettings() }()
+	dir := t.TempDir()
+
+	for _, ep := range []threshDriver{
+		{tlpDropIn{path: filepath.Join(dir, "tlp.conf"), battery: "BAT1"}},
+	} {
+		if err := ep.writable(); err != nil {
+			t.Fatalf("%v: %v", ep, err)
+		}
+		for _, p := range presets {
+			if err := ep.set(p.min, p.max); err != nil {
+				t.Fatalf("%v: %v", ep, err)
+			}
+		}
+		min, max, err := ep.get()
+		if err != nil || min != 40 || max != 70 {
+			t.Fatalf("%v: want 40-70, got %d-%d, %v", ep, min, max, err)
+		}
+	}
+}
+
+func TestParseShellVars(t *testing.T) {
+	vars := parseShellVars([]byte("# START_CHARGE_THRESH_BAT0=10\nSTART_CHARGE_THRESH_BAT0=\"75\"\n  STOP_CHARGE_THRESH_BAT0 = 80\n"))
+	if vars["START_CHARGE_THRESH_BAT0"] != "75" || vars["STOP_CHARGE_THRESH_BAT0"] != "80" {
+		t.Fatalf("wrong parse: %v", vars)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/BurntSushi/toml"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	policyPath = "/etc/matebook-applet/policy.toml"
+)
+
+// policySettings are the limits set by the administrator
+type policySettings struct {
+	Reason          string   `toml:"reason"`
+	Min             []int    `toml:"min"`
+	Max             []int    `toml:"max"`
+	Presets         []string `toml:"presets"`
+	Fnlock          string   `toml:"fnlock"`
+	KbdlightTimeout *int     `toml:"kbdlight_timeout"`
+}
+
+// policy holds the limits the applet must enforce
+var policy policySettings
+
+// loadPolicy reads the policy file, which is only trusted if it can't be
+// changed by anyone but root
+func loadPolicy(path string) {
+	fi, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err == nil {
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok && (st.Uid != 0 || fi.Mode().Perm()&0022 != 0) {
+			err = errors.New("not owned by root or writable by others")
+		}
+	}
+	var p policySettings
+	if err == nil {
+		_, err = toml.DecodeFile(path, &p)
+	}
+	if err != nil {
+		logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantReadPolicy", Other: "Ignoring policy file {{.Path}}: {{.Error}}"}, TemplateData: map[string]interface{}{"Path": path, "Error": err}}))
+		return
+	}
+	policy = p.validated()
+	logInfo.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "PolicyLoaded", Other: "Administrator policy loaded from {{.Path}}"}, TemplateData: map[string]interface{}{"Path": path}}))
+}
+
+// validated drops the limits that make no sense
+func (p policySettings) validated() policySettings {
+	validRange := func(name string, r []int) []int {
+		if r == nil || (len(r) == 2 && r[0] >= 0 && r[0] <= r[1] && r[1] <= 100) {
+			return r
+		}
+		logWarning.Printf("policy: invalid %s range %v ignored", name, r)
+		return nil
+	}
+	p.Min = validRange("min", p.Min)
+	p.Max = validRange("max", p.Max)
+	if p.Fnlock != "" && p.Fnlock != "on" && p.Fnlock != "off" {
+		logWarning.Printf("policy: invalid fnlock value %q ignored", p.Fnlock)
+		p.Fnlock = ""
+	}
+	return p
+}
+
+func (p policySettings) active() bool {
+	return p.Min != nil || p.Max != nil || p.Presets != nil || p.Fnlock != "" || p.KbdlightTimeout != nil
+}
+
+// errLocked returns the error telling the user the setting is locked and
+// why
+func (p policySettings) errLocked() error {
+	return errors.New(p.lockedReason())
+}
+
+func (p policySettings) lockedReason() string {
+	reason := p.Reason
+	if reason == "" {
+		reason = policyPath
+	}
+	return localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "LockedByPolicy", Other: "Locked by administrator policy: {{.Reason}}"}, TemplateData: map[string]interface{}{"Reason": reason}})
+}
+
+// minRange and maxRange return the allowed ranges of the thresholds
+func (p policySettings) minRange() (int, int) {
+	if p.Min == nil {
+		return 0, 100
+	}
+	return p.Min[0], p.Min[1]
+}
+
+func (p policySettings) maxRange() (int, int) {
+	if p.Max == nil {
+		return 0, 100
+	}
+	return p.Max[0], p.Max[1]
+}
+
+func (p policySettings) checkThresholds(min, max int) error {
+	minLo, minHi := p.minRange()
+	maxLo, maxHi := p.maxRange()
+	if min == 0 && max == 0 {
+		// BP OFF as some drivers report it
+		max = 100
+	}
+	if min < minLo || min > minHi || max < maxLo || max > maxHi {
+		return p.errLocked()
+	}
+	return nil
+}
+
+func (p policySettings) checkPreset(name string) error {
+	if p.Presets != nil {
+		allowed := false
+		for _, a := range p.Presets {
+			allowed = allowed || strings.EqualFold(a, name)
+		}
+		if !allowed {
+			return p.errLocked()
+		}
+	}
+	if pr, ok := findPreset(name); ok {
+		return p.checkThresholds(pr.min, pr.max)
+	}
+	return nil
+}
+
+func (p policySettings) checkFnlock(state bool) error {
+	if p.Fnlock != "" && (p.Fnlock == "on") != state {
+		return p.errLocked()
+	}
+	return nil
+}
+
+func (p policySettings) checkKbdlightTimeout(timeout int) error {
+	if p.KbdlightTimeout != nil && *p.KbdlightTimeout != timeout {
+		return p.errLocked()
+	}
+	return nil
+}
+
+// applyPolicy sets Fn-Lock and keyboard light timeout to the locked values
+// if they differ; it must only be run in the hardware goroutine
+func applyPolicy() {
+	if policy.Fnlock != "" && config.fnlock != nil && config.fnlockWritable {
+		if live, err := config.fnlock.get(); err == nil && policy.checkFnlock(live) != nil {
+			logInfo.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "ApplyingPolicyFnlock", Other: "Setting Fn-Lock as required by administrator policy"}}))
+			writeFnlock(sourcePolicy)
+		}
+	}
+	if policy.KbdlightTimeout != nil && config.kdblightTimeout != nil && config.kdblightTimeoutWritable {
+		if live, err := config.kdblightTimeout.get(); err == nil && live != *policy.KbdlightTimeout {
+			logInfo.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "ApplyingPolicyKdblightTimeout", Other: "Setting keyboard light timeout as required by administrator policy"}}))
+			writeKbdlightTimeout(*policy.KbdlightTimeout, sourcePolicy)
+		}
+	}
+}
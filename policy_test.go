@@ -0,0 +1,83 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	timeout := 0
+	p := policySettings{Min: []int{40, 80}, Max: []int{60, 90}, Presets: []string{"home", "office", "custom"}, Fnlock: "on", KbdlightTimeout: &timeout}.validated()
+
+	if err := p.checkThresholds(40, 70); err != nil {
+		t.Errorf("40-70 not allowed: %v", err)
+	}
+	for _, th := range [][2]int{{0, 100}, {0, 0}, {70, 95}, {20, 60}} {
+		if err := p.checkThresholds(th[0], th[1]); err == nil {
+			t.Errorf("%d-%d allowed", th[0], th[1])
+		}
+	}
+	if err := p.checkPreset("office"); err != nil {
+		t.Errorf("office not allowed: %v", err)
+	}
+	if err := p.checkPreset("travel"); err == nil {
+		t.Error("travel allowed")
+	}
+	if err := p.checkFnlock(false); err == nil {
+		t.Error("Fn-Lock off allowed")
+	}
+	if err := p.checkKbdlightTimeout(10); err == nil {
+		t.Error("keyboard light timeout change allowed")
+	}
+
+	if v := (policySettings{Min: []int{80, 40}, Fnlock: "yes"}).validated(); v.active() {
+		t.Errorf("invalid limits kept: %+v", v)
+	}
+}
+
+func TestPolicyEnforced(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	policy = policySettings{Max: []int{60, 90}}
+	defer func() { policy = policySettings{} }()
+	drv := &mockDriver{40, 70}
+	config.thresh, config.threshWritable = threshDriver{drv}, true
+	defer func() { config.thresh, config.threshWritable = nil, false }()
+
+	if err := writeThresholds(0, 100, sourceUser); err == nil {
+		t.Fatal("thresholds set against the policy")
+	}
+	if drv.vMin != 40 || drv.vMax != 70 {
+		t.Fatalf("want: 40-70, got: %d-%d", drv.vMin, drv.vMax)
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.toml")
+	if err := os.WriteFile(path, []byte("max = [60, 90]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { policy = policySettings{} }()
+	loadPolicy(path)
+	if os.Getuid() == 0 && !policy.active() {
+		t.Error("policy owned by root not loaded")
+	}
+	if os.Getuid() != 0 && policy.active() {
+		t.Error("policy not owned by root loaded")
+	}
+}
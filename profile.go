@@ -0,0 +1,423 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/BurntSushi/toml"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	maxKbdlightTimeout = 24 * 60 * 60
+)
+
+// profile is what is carried over to another laptop: the live settings
+// and the sections of the configuration file that matter
+type profile struct {
+	Min             *int            `toml:"min"`
+	Max             *int            `toml:"max"`
+	Fnlock          string          `toml:"fnlock,omitempty"`
+	KbdlightTimeout *int            `toml:"kbdlight_timeout"`
+	Travel          *travelSettings `toml:"travel"`
+	Hooks           *hookSettings   `toml:"hooks"`
+	Presets         *[]customPreset `toml:"presets"`
+}
+
+// profileChange is what importing the profile changes, err tells why the
+// change can't be made
+type profileChange struct {
+	what     string
+	old, new string
+	err      error
+	apply    func() error
+}
+
+// findEndpoints looks for the endpoints to use
+func findEndpoints() {
+	addScriptEndpoints()
+	addExternalEndpoints()
+	findFnlock()
+	findThresh()
+	findKdblightTimeout()
+}
+
+// currentProfile returns the profile of the current state
+func currentProfile(st hwStatus) profile {
+	var p profile
+	if st.thresh && st.threshErr == nil {
+		min, max := st.min, st.max
+		p.Min, p.Max = &min, &max
+	}
+	if st.fnlock && st.fnlockErr == nil {
+		p.Fnlock = onOff(st.fnlockState)
+	}
+	if st.kdblightTimeout && st.timeoutErr == nil {
+		timeout := st.timeout
+		p.KbdlightTimeout = &timeout
+	}
+	travel, hooks := settings.Travel, settings.Hooks
+	p.Travel, p.Hooks = &travel, &hooks
+	custom := []customPreset{}
+	for _, c := range customPresets() {
+		custom = append(custom, customPreset{Name: c.name, Min: c.min, Max: c.max})
+	}
+	p.Presets = &custom
+	return p
+}
+
+// exportProfile writes the profile of the current state
+func exportProfile(w io.Writer) error {
+	findEndpoints()
+	hwDo(updateStatus)
+	return toml.NewEncoder(w).Encode(currentProfile(cachedStatus()))
+}
+
+// readProfile reads the profile, the sections of the configuration file
+// missing from it are kept as they are
+func readProfile(path string) (profile, error) {
+	cur := currentProfile(hwStatus{})
+	p := profile{Travel: cur.Travel, Hooks: cur.Hooks, Presets: cur.Presets}
+	md, err := toml.DecodeFile(path, &p)
+	if err != nil {
+		return p, err
+	}
+	if keys := md.Undecoded(); len(keys) > 0 {
+		return p, fmt.Errorf("%s: unknown key %s", path, keys[0])
+	}
+	if (p.Min == nil) != (p.Max == nil) {
+		return p, fmt.Errorf("%s: both min and max are required", path)
+	}
+	if _, errs := parseCustomPresets(*p.Presets); len(errs) > 0 {
+		return p, fmt.Errorf("%s: %w", path, errs[0])
+	}
+	return p, nil
+}
+
+// planImport returns what importing the profile changes
+func planImport(p profile, st hwStatus) []profileChange {
+	cur := currentProfile(st)
+	unsupported := errors.New(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "NotSupportedHere", Other: "not supported on this hardware"}}))
+	unknown := "-"
+
+	var changes []profileChange
+	if p.Min != nil {
+		min, max := *p.Min, *p.Max
+		c := profileChange{what: "thresholds", old: unknown, new: formatThresholds(min, max)}
+		if cur.Min != nil {
+			c.old = formatThresholds(*cur.Min, *cur.Max)
+		}
+		switch {
+		case min < 0 || max > 100 || min > max:
+			c.err = fmt.Errorf("invalid thresholds %d-%d", min, max)
+		case !st.thresh || !st.threshWritable:
+			c.err = unsupported
+		default:
+			c.err = policy.checkThresholds(min, max)
+		}
+		c.apply = func() error { return setThresholds(min, max, sourceImport) }
+		if cur.Min == nil || !sameThresholds(*cur.Min, *cur.Max, min, max) {
+			changes = append(changes, c)
+		}
+	}
+	if p.Fnlock != "" {
+		c := profileChange{what: "fnlock", old: unknown, new: p.Fnlock}
+		if cur.Fnlock != "" {
+			c.old = cur.Fnlock
+		}
+		switch {
+		case p.Fnlock != "on" && p.Fnlock != "off":
+			c.err = fmt.Errorf("invalid fnlock value %q", p.Fnlock)
+		case !st.fnlock || !st.fnlockWritable:
+			c.err = unsupported
+		case cur.Fnlock == "":
+			// it can only be toggled, so it has to be known
+			c.err = fmt.Errorf("can't read the current state: %w", st.fnlockErr)
+		default:
+			c.err = policy.checkFnlock(p.Fnlock == "on")
+		}
+		c.apply = func() error { return toggleFnlock(sourceImport) }
+		if cur.Fnlock != p.Fnlock {
+			changes = append(changes, c)
+		}
+	}
+	if p.KbdlightTimeout != nil {
+		timeout := *p.KbdlightTimeout
+		c := profileChange{what: "kbdlight_timeout", old: unknown, new: strconv.Itoa(timeout)}
+		if cur.KbdlightTimeout != nil {
+			c.old = strconv.Itoa(*cur.KbdlightTimeout)
+		}
+		switch {
+		case timeout < 0 || timeout > maxKbdlightTimeout:
+			c.err = fmt.Errorf("invalid keyboard light timeout %d", timeout)
+		case !st.kdblightTimeout || !st.kdblightTimeoutWritable:
+			c.err = unsupported
+		default:
+			c.err = policy.checkKbdlightTimeout(timeout)
+		}
+		c.apply = func() error { return setKbdlightTimeout(timeout, sourceImport) }
+		if cur.KbdlightTimeout == nil || *cur.KbdlightTimeout != timeout {
+			changes = append(changes, c)
+		}
+	}
+	changes = append(changes, diffSection("travel", *cur.Travel, *p.Travel)...)
+	changes = append(changes, diffSection("hooks", *cur.Hooks, *p.Hooks)...)
+	if p.Presets != nil {
+		changes = append(changes, diffPresets(*cur.Presets, *p.Presets)...)
+	}
+	return changes
+}
+
+// diffPresets returns the changes of the custom presets, these are applied
+// all at once along with the sections of the configuration file
+func diffPresets(old, new []customPreset) []profileChange {
+	thresholds := func(custom []customPreset) map[string]string {
+		m := make(map[string]string)
+		for _, c := range custom {
+			m[strings.ToLower(strings.TrimSpace(c.Name))] = formatThresholds(c.Min, c.Max)
+		}
+		return m
+	}
+	om, nm := thresholds(old), thresholds(new)
+	var names []string
+	for name := range om {
+		names = append(names, name)
+	}
+	for name := range nm {
+		if _, ok := om[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []profileChange
+	for _, name := range names {
+		o, n := om[name], nm[name]
+		if o == n {
+			continue
+		}
+		if o == "" {
+			o = "-"
+		}
+		if n == "" {
+			n = "-"
+		}
+		changes = append(changes, profileChange{what: "[[presets]] " + name, old: o, new: n})
+	}
+	return changes
+}
+
+// tableOf returns the TOML table v is encoded to
+func tableOf(v interface{}) (map[string]interface{}, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	m := make(map[string]interface{})
+	_, err := toml.Decode(buf.String(), &m)
+	return m, err
+}
+
+// diffSection returns the changes of the keys in the section of the
+// configuration file, these are applied all at once
+func diffSection(name string, old, new interface{}) []profileChange {
+	om, err := tableOf(old)
+	if err != nil {
+		return []profileChange{{what: "[" + name + "]", err: err}}
+	}
+	nm, err := tableOf(new)
+	if err != nil {
+		return []profileChange{{what: "[" + name + "]", err: err}}
+	}
+	keys := make(map[string]bool)
+	for k := range om {
+		keys[k] = true
+	}
+	for k := range nm {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	format := func(v interface{}) string {
+		switch v := v.(type) {
+		case nil:
+			return "-"
+		case string:
+			return strconv.Quote(v)
+		}
+		return fmt.Sprint(v)
+	}
+	var changes []profileChange
+	for _, k := range sorted {
+		if o, n := format(om[k]), format(nm[k]); o != n {
+			changes = append(changes, profileChange{what: "[" + name + "] " + k, old: o, new: n})
+		}
+	}
+	return changes
+}
+
+// writeProfileSettings puts the sections of the profile into the
+// configuration file in place of the ones there, keeping the rest of it
+// as it is; the previous version of the file is kept in the backup file
+// returned (if there was one)
+func writeProfileSettings(path string, p profile) (backup string, err error) {
+	b, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	if err == nil {
+		// comments in the sections replaced are lost
+		backup = path + ".bak"
+		if err := os.WriteFile(backup, b, 0600); err != nil {
+			return "", err
+		}
+	}
+
+	sections := []string{"travel", "hooks"}
+	m := map[string]interface{}{"travel": p.Travel, "hooks": p.Hooks}
+	if p.Presets != nil {
+		sections = append(sections, "presets")
+		// an empty array would end up in the table before it
+		if len(*p.Presets) > 0 {
+			m["presets"] = *p.Presets
+		}
+	}
+	var buf bytes.Buffer
+	if rest := strings.TrimRight(stripSections(string(b), sections), "\n"); rest != "" {
+		buf.WriteString(rest + "\n\n")
+	}
+	if err := toml.NewEncoder(&buf).Encode(m); err != nil {
+		return backup, err
+	}
+	return backup, os.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// stripSections removes the tables with the names given (along with their
+// sub-tables) from TOML text, the comments right before a table are taken
+// as belonging to it
+func stripSections(s string, names []string) string {
+	var b, pending strings.Builder
+	skip := false
+	for _, line := range strings.SplitAfter(s, "\n") {
+		t := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(t, "["):
+			header, _, _ := strings.Cut(t, "#")
+			name := strings.Trim(header, "[] \t")
+			skip = false
+			for _, n := range names {
+				if name == n || strings.HasPrefix(name, n+".") {
+					skip = true
+				}
+			}
+			if !skip {
+				b.WriteString(pending.String())
+			}
+			pending.Reset()
+		case t == "" || strings.HasPrefix(t, "#"):
+			pending.WriteString(line)
+			continue
+		case skip:
+			pending.Reset()
+		default:
+			b.WriteString(pending.String())
+			pending.Reset()
+		}
+		if !skip {
+			b.WriteString(line)
+		}
+	}
+	if !skip {
+		b.WriteString(pending.String())
+	}
+	return b.String()
+}
+
+// importProfile shows what the profile changes and applies it unless it's
+// a dry run
+func importProfile(path string, dryRun bool, w io.Writer) error {
+	p, err := readProfile(path)
+	if err != nil {
+		return err
+	}
+	findEndpoints()
+	findPersistence()
+	hwDo(updateStatus)
+	changes := planImport(p, cachedStatus())
+	if len(changes) == 0 {
+		fmt.Fprintln(w, localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "ProfileNoChanges", Other: "Nothing to change"}}))
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tOLD\tNEW\tNOTE")
+	for _, c := range changes {
+		note := ""
+		if c.err != nil {
+			note = "skipped: " + c.err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", c.what, c.old, c.new, note)
+	}
+	tw.Flush()
+	if dryRun {
+		return nil
+	}
+
+	var failed []string
+	writeSettings := false
+	for _, c := range changes {
+		switch {
+		case c.err != nil:
+		case c.apply == nil:
+			writeSettings = true
+		default:
+			if err := c.apply(); err != nil {
+				failed = append(failed, fmt.Sprintf("%s: %v", c.what, err))
+			}
+		}
+	}
+	if writeSettings {
+		if backup, err := writeProfileSettings(settingsPath, p); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", settingsPath, err))
+		} else if backup != "" {
+			fmt.Fprintln(w, localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "ProfileSettingsReplaced", Other: "Configuration file {{.Path}} updated, the sections replaced lose their comments, the previous version is saved to {{.Backup}}"}, TemplateData: map[string]interface{}{"Path": settingsPath, "Backup": backup}}))
+		} else {
+			fmt.Fprintln(w, localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "ProfileSettingsWritten", Other: "Configuration file {{.Path}} updated"}, TemplateData: map[string]interface{}{"Path": settingsPath}}))
+		}
+	}
+	if len(failed) > 0 {
+		return errors.New(strings.Join(failed, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+)
+
+func TestProfileRoundTrip(t *testing.T) {
+	addCustomPresets([]customPreset{{Name: "Work", Min: 60, Max: 80}})
+	defer addCustomPresets(nil)
+	st := hwStatus{thresh: true, threshWritable: true, min: 40, max: 70, fnlock: true, fnlockWritable: true, fnlockState: true}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(currentProfile(st)); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "profile.toml")
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := readProfile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Min == nil || *p.Min != 40 || *p.Max != 70 || p.Fnlock != "on" || p.KbdlightTimeout != nil || len(*p.Presets) != 1 || (*p.Presets)[0] != (customPreset{"work", 60, 80}) {
+		t.Errorf("unexpected profile read back:\n%s", buf.String())
+	}
+	if changes := planImport(p, st); len(changes) != 0 {
+		t.Errorf("want no changes, got %+v", changes)
+	}
+}
+
+func TestPlanImport(t *testing.T) {
+	st := hwStatus{thresh: true, threshWritable: true, min: 40, max: 70, kdblightTimeout: true, kdblightTimeoutWritable: true}
+	min, max, timeout := 70, 90, 300
+	travel, hooks := settings.Travel, settings.Hooks
+	travel.Calendar = "~/trips.ics"
+	custom := []customPreset{{Name: "work", Min: 60, Max: 80}}
+	p := profile{Min: &min, Max: &max, Fnlock: "on", KbdlightTimeout: &timeout, Travel: &travel, Hooks: &hooks, Presets: &custom}
+
+	changes := planImport(p, st)
+	want := []struct {
+		what, old, new string
+		ok             bool
+	}{
+		{"thresholds", "40-70", "70-90", true},
+		{"fnlock", "-", "on", false},
+		{"kbdlight_timeout", "0", "300", true},
+		{"[travel] calendar", `""`, `"~/trips.ics"`, true},
+		{"[[presets]] work", "-", "60-80", true},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("want %d changes, got %+v", len(want), changes)
+	}
+	for i, c := range changes {
+		w := want[i]
+		if c.what != w.what || c.old != w.old || c.new != w.new || (c.err == nil) != w.ok {
+			t.Errorf("change %d: want %+v, got %+v", i, w, c)
+		}
+	}
+
+	min, max = 90, 70
+	if changes := planImport(profile{Min: &min, Max: &max, Travel: &travel, Hooks: &hooks}, st); len(changes) == 0 || changes[0].err == nil {
+		t.Error("invalid thresholds accepted")
+	}
+
+	st.fnlock, st.fnlockWritable, st.fnlockErr = true, true, errors.New("read failed")
+	if changes := planImport(profile{Fnlock: "on", Travel: &travel, Hooks: &hooks}, st); len(changes) == 0 || changes[0].err == nil {
+		t.Error("Fn-Lock toggled from an unknown state")
+	}
+}
+
+func TestWriteProfileSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	orig := "# my settings\npoll_interval = \"5s\" # often\n\n[[presets]]\nname = \"old\"\nmin = 50\nmax = 60\n\n[travel] # trips\ncalendar = \"old.ics\"\n\n[travel.extra]\nx = 1\n\n# keep it\n[conflicts]\ntake_over = true\n"
+	if err := os.WriteFile(path, []byte(orig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	travel, hooks := settings.Travel, settings.Hooks
+	travel.Calendar = "new.ics"
+	hooks.OnFnlockChanged = "notify-send fnlock"
+	custom := []customPreset{{Name: "work", Min: 60, Max: 80}}
+	backup, err := writeProfileSettings(path, profile{Travel: &travel, Hooks: &hooks, Presets: &custom})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := defaultSettings()
+	if _, err := toml.DecodeFile(path, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.PollInterval.String() != "5s" || !s.Conflicts.TakeOver || s.Travel.Calendar != "new.ics" || s.Hooks.OnFnlockChanged != "notify-send fnlock" || s.Travel.LeadTime != defaultTravelLeadTime || len(s.Presets) != 1 || s.Presets[0] != custom[0] {
+		t.Errorf("unexpected settings: %+v", s)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# my settings\npoll_interval = \"5s\" # often\n\n# keep it\n[conflicts]\ntake_over = true\n\n"; !strings.HasPrefix(string(b), want) {
+		t.Errorf("the rest of the file not kept:\n%s", b)
+	}
+	if b, err := os.ReadFile(backup); err != nil || string(b) != orig {
+		t.Errorf("no backup: %q, %v", b, err)
+	}
+}
+
+func TestImportWhileRunning(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	if !acquireInstanceLock() {
+		t.Fatal("failed to acquire lock")
+	}
+	defer func() {
+		instanceLock.Close()
+		instanceLock = nil
+	}()
+
+	// nothing to change, so it would succeed otherwise
+	path := filepath.Join(t.TempDir(), "profile.toml")
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := runCommand("import", []string{"-dry-run", path}); err != nil {
+		t.Fatal(err)
+	}
+	if err := runCommand("import", []string{path}); err == nil {
+		t.Fatal("import allowed while another instance is running")
+	}
+}
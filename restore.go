@@ -0,0 +1,110 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	resumeCheckInterval = 10 * time.Second
+
+	// wall clock running ahead of monotonic clock by this much means
+	// the system has been suspended
+	resumeJump = 5 * time.Second
+)
+
+// restoreThresholds re-applies the saved thresholds if the live ones
+// differ, returning the description of what it did (if anything); it must
+// only be run in the hardware goroutine
+func restoreThresholds() (string, error) {
+	if config.thresh == nil || config.threshPers == nil {
+		return "", nil
+	}
+	min, max, err := config.threshPers.get()
+	if err != nil {
+		return "", err
+	}
+	liveMin, liveMax, err := config.thresh.get()
+	if err == nil && sameThresholds(min, max, liveMin, liveMax) {
+		logTrace.Println("live thresholds match the saved ones")
+		return "", nil
+	}
+	if !config.threshWritable {
+		return "", errors.New("no writable battery thresholds endpoint")
+	}
+	logTrace.Printf("live thresholds %d-%d, saved %d-%d, restoring", liveMin, liveMax, min, max)
+	if err := writeThresholds(min, max, sourceRestore); err != nil {
+		return "", err
+	}
+	return localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "ThresholdsRestored", Other: "Battery protection thresholds were {{.OldMin}}%-{{.OldMax}}%, restored the saved {{.Min}}%-{{.Max}}%"}, TemplateData: map[string]interface{}{"OldMin": liveMin, "OldMax": liveMax, "Min": min, "Max": max}}), nil
+}
+
+// checkRestore restores the saved thresholds (if the user wants it) and
+// updates the state shown
+func checkRestore() {
+	if !settings.RestoreThresholds {
+		return
+	}
+	var msg string
+	var err error
+	hwDo(func() {
+		msg, err = restoreThresholds()
+		if msg != "" {
+			updateStatus()
+		}
+	})
+	if err != nil {
+		logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantRestoreThresholds", Other: "Failed to restore saved thresholds: {{.Error}}"}, TemplateData: map[string]interface{}{"Error": err}}))
+	}
+	if msg != "" {
+		logInfo.Println(msg)
+		sendNotification(eventRestored, msg)
+		stateChanged()
+	}
+}
+
+// watchResume checks the thresholds after the system resumes from suspend,
+// which is noticed by wall clock jumping ahead of the monotonic one
+func watchResume() {
+	prev := time.Now()
+	for range time.Tick(resumeCheckInterval) {
+		now := time.Now()
+		wasSuspended := resumed(prev, now)
+		prev = now
+		if !wasSuspended {
+			continue
+		}
+		logTrace.Println("system has resumed")
+		requestDiscovery()
+		checkRestore()
+	}
+}
+
+// resumed tells whether more wall clock time has passed between prev and
+// now than the monotonic one
+func resumed(prev, now time.Time) bool {
+	return clockJumped(now.Round(0).Sub(prev.Round(0)), now.Sub(prev))
+}
+
+// clockJumped tells whether the wall clock has gone ahead of the monotonic one
+// by more than can be put down to a drift
+func clockJumped(wall, mono time.Duration) bool {
+	return wall-mono > resumeJump
+}
@@ -0,0 +1,60 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRestoreThresholds(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	settings.VerifyTimeout = 10 * time.Millisecond
+	defer func() { settings = defaultSettings() }()
+
+	live := &mockDriver{0, 100}
+	saved := &mockDriver{40, 70}
+	config.thresh, config.threshPers, config.threshWritable = threshDriver{live}, threshDriver{saved}, true
+	defer func() { config.thresh, config.threshPers, config.threshWritable = nil, nil, false }()
+
+	msg, err := restoreThresholds()
+	if err != nil || msg == "" {
+		t.Fatalf("not restored: %v", err)
+	}
+	if live.vMin != 40 || live.vMax != 70 {
+		t.Fatalf("want: 40-70, got: %d-%d", live.vMin, live.vMax)
+	}
+
+	msg, err = restoreThresholds()
+	if err != nil || msg != "" {
+		t.Fatalf("restored when thresholds match: %v", err)
+	}
+}
+
+func TestResumed(t *testing.T) {
+	prev := time.Now()
+	if resumed(prev, prev.Add(resumeCheckInterval)) {
+		t.Fatal("resume detected without a wall clock jump")
+	}
+	// a time value can't be made to carry a wall clock jump, so check the
+	// comparison itself
+	if !clockJumped(resumeCheckInterval+resumeJump+time.Second, resumeCheckInterval) {
+		t.Fatal("resume not detected after a wall clock jump")
+	}
+	if clockJumped(resumeCheckInterval+resumeJump/2, resumeCheckInterval) {
+		t.Fatal("resume detected after a drift")
+	}
+}
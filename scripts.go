@@ -0,0 +1,269 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	defaultScriptTimeout = 10 * time.Second
+)
+
+// scriptSettings are the command templates to use for scripts (-r)
+type scriptSettings struct {
+	Timeout      time.Duration `toml:"timeout"`
+	ThreshGet    cmdTemplate   `toml:"thresh_get"`
+	ThreshSet    cmdTemplate   `toml:"thresh_set"`
+	ThreshOff    cmdTemplate   `toml:"thresh_off"`
+	FnlockGet    cmdTemplate   `toml:"fnlock_get"`
+	FnlockToggle cmdTemplate   `toml:"fnlock_toggle"`
+}
+
+func defaultScriptSettings() scriptSettings {
+	return scriptSettings{
+		Timeout:      defaultScriptTimeout,
+		ThreshGet:    sudoPath + " -n batpro status",
+		ThreshSet:    sudoPath + " -n batpro custom {min} {max}",
+		ThreshOff:    sudoPath + " -n batpro off",
+		FnlockGet:    sudoPath + " -n fnlock status",
+		FnlockToggle: sudoPath + " -n fnlock toggle",
+	}
+}
+
+// cmdTemplate is a command line with placeholders like {min} that are
+// replaced with the actual values every time the command is run
+type cmdTemplate string
+
+type fnlockScript struct {
+	getCmd    cmdTemplate
+	toggleCmd cmdTemplate
+}
+
+type threshScript struct {
+	getCmd cmdTemplate
+	setCmd cmdTemplate
+	offCmd cmdTemplate
+}
+
+// addScriptEndpoints makes scripts available as endpoints of last resort
+func addScriptEndpoints() {
+	if !config.useScripts {
+		return
+	}
+	s := settings.Scripts
+	if s.FnlockGet != "" && s.FnlockToggle != "" {
+		fnlockEndpoints = append(fnlockEndpoints, fnlockScript{getCmd: s.FnlockGet, toggleCmd: s.FnlockToggle})
+	}
+	if s.ThreshGet != "" && s.ThreshSet != "" {
+		threshEndpoints = append(threshEndpoints, threshScript{getCmd: s.ThreshGet, setCmd: s.ThreshSet, offCmd: s.ThreshOff})
+	}
+}
+
+// checkVars are the values the placeholders are replaced with when
+// checking whether the command may be run; a sudoers rule that allows any
+// thresholds allows these as well
+var checkVars = map[string]string{"min": "40", "max": "70"}
+
+// args returns the command line split into arguments with placeholders
+// replaced, a value with spaces stays a single argument
+func (t cmdTemplate) args(vars map[string]string) ([]string, error) {
+	fields, err := splitCommand(string(t))
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range fields {
+		for k, v := range vars {
+			f = strings.ReplaceAll(f, "{"+k+"}", v)
+		}
+		fields[i] = f
+	}
+	return fields, nil
+}
+
+// splitCommand splits the command line into arguments the way the shell
+// does, honouring single and double quotes and backslash escapes
+func splitCommand(s string) ([]string, error) {
+	var (
+		args    []string
+		cur     strings.Builder
+		inArg   bool
+		escaped bool
+		quote   rune
+	)
+	for _, r := range s {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inArg = true, true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in %q", s)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+// run builds a fresh command from the template and runs it with scripts
+// timeout, returning its standard output
+func (t cmdTemplate) run(vars map[string]string) (string, error) {
+	out, err := t.runWith(settings.Scripts.Timeout, vars, nil)
+	return string(out), err
+}
+
+// runWith builds a fresh command from the template with extra arguments
+// appended and runs it, feeding it stdin and returning its standard output
+func (t cmdTemplate) runWith(timeout time.Duration, vars map[string]string, stdin []byte, extra ...string) ([]byte, error) {
+	args, err := t.args(vars)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, extra...)
+	if len(args) == 0 {
+		return nil, errors.New("empty command")
+	}
+	if timeout <= 0 {
+		timeout = defaultScriptTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	logTrace.Printf("running %q", args)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	out, err := cmd.Output()
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	return out, err
+}
+
+func (scr fnlockScript) writable() error {
+	args, err := scr.toggleCmd.args(nil)
+	if err != nil {
+		return err
+	}
+	return checkCommand(args)
+}
+
+func (scr fnlockScript) String() string {
+	return string(scr.toggleCmd)
+}
+
+func (scr fnlockScript) get() (bool, error) {
+	out, err := scr.getCmd.run(nil)
+	if err != nil {
+		logError.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantReadFnlockScript", Other: "Failed to get fnlock status from script"}}))
+	}
+	state := parseOnOffStatus(out)
+	if state == "on" {
+		return true, err
+	}
+	return false, err
+}
+
+func (scr fnlockScript) toggle() error {
+	_, err := scr.toggleCmd.run(nil)
+	if err != nil {
+		logError.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantToggleFnlock", Other: "Failed to toggle Fn-Lock"}}))
+	}
+	return err
+}
+
+func (scr threshScript) writable() error {
+	args, err := scr.setCmd.args(checkVars)
+	if err != nil {
+		return err
+	}
+	return checkCommand(args)
+}
+
+func (scr threshScript) String() string {
+	return string(scr.setCmd)
+}
+
+func (scr threshScript) get() (min, max int, err error) {
+	out, err := scr.getCmd.run(nil)
+	if err != nil {
+		logError.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantReadBatteryScript", Other: "Failed to get battery protection status from script"}}))
+		return
+	}
+	state, min, max := parseStatus(out)
+	if state == "off" {
+		min = 0
+		max = 100
+	}
+	return
+}
+
+func (scr threshScript) set(min, max int) error {
+	return setVerified(scr.write, scr.get, min, max)
+}
+
+func (scr threshScript) write(min, max int) error {
+	cmd := scr.setCmd
+	if min == 0 && max == 100 && scr.offCmd != "" {
+		cmd = scr.offCmd
+	}
+	_, err := cmd.run(map[string]string{
+		"min": strconv.Itoa(min),
+		"max": strconv.Itoa(max),
+	})
+	if err != nil {
+		logError.Println(localizer.MustLocalize(&i18n.LocalizeConfig{MessageID: "CantSetBattery"}))
+	}
+	return err
+}
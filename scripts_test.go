@@ -0,0 +1,94 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const fakeBatpro = `#!/bin/sh
+state="$(dirname "$0")/state"
+case "$1" in
+custom) echo "$2 $3" > "$state" ;;
+off) echo "0 100" > "$state" ;;
+status)
+	read min max < "$state"
+	if [ "$min" = 0 ] && [ "$max" = 100 ]; then
+		echo "battery protection is off"
+	else
+		printf "battery protection is on\nthresholds:\nminimum %s %%\nmaximum %s %%\n" "$min" "$max"
+	fi
+	;;
+esac
+`
+
+func TestThreshScript(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "batpro")
+	if err := os.WriteFile(script, []byte(fakeBatpro), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "state"), []byte("0 100\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	scr := threshScript{
+		getCmd: cmdTemplate(script + " status"),
+		setCmd: cmdTemplate(script + " custom {min} {max}"),
+		offCmd: cmdTemplate(script + " off"),
+	}
+
+	// every command must be usable more than once
+	for _, tc := range []struct{ min, max int }{{40, 70}, {70, 90}, {0, 100}, {95, 100}} {
+		if err := scr.set(tc.min, tc.max); err != nil {
+			t.Fatal(err)
+		}
+		min, max, err := scr.get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if min != tc.min || max != tc.max {
+			t.Fatalf("want: %d-%d, got: %d-%d", tc.min, tc.max, min, max)
+		}
+	}
+}
+
+func TestCmdTemplateArgs(t *testing.T) {
+	vars := map[string]string{"min": "40", "max": "70", "name": "a b"}
+	for _, tc := range []struct {
+		tmpl string
+		want []string
+	}{
+		{"sudo -n batpro custom {min} {max}", []string{"sudo", "-n", "batpro", "custom", "40", "70"}},
+		{`"/opt/my tools/batpro" 'custom  {min}' {max}`, []string{"/opt/my tools/batpro", "custom  40", "70"}},
+		{`/opt/my\ tools/batpro "say \"hi\"" {name}`, []string{"/opt/my tools/batpro", `say "hi"`, "a b"}},
+		{`tool ""`, []string{"tool", ""}},
+	} {
+		got, err := cmdTemplate(tc.tmpl).args(vars)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.tmpl, err)
+		}
+		if strings.Join(got, "|") != strings.Join(tc.want, "|") || len(got) != len(tc.want) {
+			t.Errorf("%s: want: %q, got: %q", tc.tmpl, tc.want, got)
+		}
+	}
+	if _, err := cmdTemplate(`tool "unterminated`).args(nil); err == nil {
+		t.Error("unterminated quote accepted")
+	}
+}
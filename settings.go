@@ -0,0 +1,140 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	settingsDir  = "matebook-applet"
+	settingsFile = "config.toml"
+)
+
+// settings holds the user preferences read from the configuration file
+var settings = defaultSettings()
+
+type appletSettings struct {
+	PollInterval      time.Duration        `toml:"poll_interval"`
+	DiscoveryInterval time.Duration        `toml:"discovery_interval"`
+	VerifyTimeout     time.Duration        `toml:"verify_timeout"`
+	RestoreThresholds bool                 `toml:"restore_thresholds"`
+	ThreshPersistence string               `toml:"thresh_persistence"`
+	TopUpDeadline     time.Duration        `toml:"top_up_deadline"`
+	UsageHistory      bool                 `toml:"usage_history"`
+	Hooks             hookSettings         `toml:"hooks"`
+	Notifications     notificationSettings `toml:"notifications"`
+	Scripts           scriptSettings       `toml:"scripts"`
+	External          externalSettings     `toml:"external"`
+	Conflicts         conflictSettings     `toml:"conflicts"`
+	Calibration       calibrationSettings  `toml:"calibration"`
+	Travel            travelSettings       `toml:"travel"`
+	Health            healthSettings       `toml:"health"`
+	Presets           []customPreset       `toml:"presets"`
+}
+
+func defaultSettings() appletSettings {
+	return appletSettings{
+		PollInterval:      defaultPollInterval,
+		DiscoveryInterval: defaultDiscoveryInterval,
+		VerifyTimeout:     defaultVerifyTimeout,
+		ThreshPersistence: persistHuaweiWMI,
+		TopUpDeadline:     defaultTopUpDeadline,
+		UsageHistory:      true,
+		Hooks: hookSettings{
+			Timeout: defaultHookTimeout,
+		},
+		Notifications: notificationSettings{
+			Failures:    true,
+			Restored:    true,
+			TopUp:       true,
+			Calibration: true,
+			Travel:      true,
+			Health:      true,
+			MinInterval: defaultNotifyInterval,
+		},
+		Scripts: defaultScriptSettings(),
+		External: externalSettings{
+			Timeout: defaultScriptTimeout,
+		},
+		Conflicts: conflictSettings{
+			RevertDelay: defaultRevertDelay,
+		},
+		Calibration: calibrationSettings{
+			LowLevel:       defaultCalibrationLow,
+			ForceDischarge: true,
+		},
+		Travel: travelSettings{
+			Tag:      defaultTravelTag,
+			Preset:   defaultTravelPreset,
+			LeadTime: defaultTravelLeadTime,
+		},
+		Health: healthSettings{
+			FullDays: defaultHealthFullDays,
+			Preset:   defaultHealthPreset,
+		},
+	}
+}
+
+// defaultSettingsPath returns the path of the configuration file in user's
+// config directory
+func defaultSettingsPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		logTrace.Println(err)
+		return ""
+	}
+	return filepath.Join(dir, settingsDir, settingsFile)
+}
+
+// userStateDir returns the directory for the state the applet keeps
+// between runs, following XDG base directory specification
+func userStateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, settingsDir), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state", settingsDir), nil
+}
+
+// loadSettings reads the configuration file, a missing file is not an error
+func loadSettings(path string) {
+	if path == "" {
+		return
+	}
+	s := defaultSettings()
+	if _, err := toml.DecodeFile(path, &s); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			logTrace.Printf("no configuration file at %q, using defaults", path)
+			return
+		}
+		logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantReadSettings", Other: "Failed to read configuration file {{.Path}}, using defaults"}, TemplateData: map[string]interface{}{"Path": path}}))
+		logTrace.Println(err)
+		return
+	}
+	logTrace.Printf("configuration loaded from %q", path)
+	settings = s
+}
@@ -0,0 +1,217 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	defaultTopUpDeadline = 12 * time.Hour
+	topUpCheckInterval   = time.Minute
+	topUpStateFile       = "top_up.json"
+)
+
+// topUpState is what a one-time full charge has to restore when it's over,
+// it is kept in a file so that an applet restart doesn't lose it
+type topUpState struct {
+	Min      int       `json:"min"`
+	Max      int       `json:"max"`
+	Deadline time.Time `json:"deadline"`
+}
+
+var topUpMutex sync.Mutex
+
+// loadTopUp returns the state of the top-up in progress, or nil if there's
+// none
+func loadTopUp() (*topUpState, error) {
+	var s topUpState
+	if ok, err := readState(topUpStateFile, &s); !ok || err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func saveTopUp(s topUpState) error {
+	return writeState(topUpStateFile, s)
+}
+
+func clearTopUp() {
+	removeState(topUpStateFile)
+}
+
+// topUpInProgress returns the state of the top-up in progress, or nil if
+// there's none
+func topUpInProgress() *topUpState {
+	topUpMutex.Lock()
+	defer topUpMutex.Unlock()
+	s, err := loadTopUp()
+	if err != nil {
+		logTrace.Println(err)
+	}
+	return s
+}
+
+// startTopUp switches battery protection off until the battery is full,
+// remembering the thresholds to restore
+func startTopUp() error {
+	if calibrationInProgress() != nil {
+		return errors.New(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CalibrationInProgress", Other: "Battery calibration is already in progress"}}))
+	}
+	topUpMutex.Lock()
+	defer topUpMutex.Unlock()
+	if s, _ := loadTopUp(); s != nil {
+		return errors.New(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "TopUpInProgress", Other: "Already charging fully"}}))
+	}
+	refreshStatus()
+	st := cachedStatus()
+	if !st.thresh || !st.threshWritable || st.threshErr != nil {
+		return errNotAvailable()
+	}
+	if sameThresholds(st.min, st.max, 0, 100) {
+		return errors.New(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "TopUpProtectionOff", Other: "Battery protection is off already"}}))
+	}
+
+	deadline := settings.TopUpDeadline
+	if deadline <= 0 {
+		deadline = defaultTopUpDeadline
+	}
+	s := topUpState{Min: st.min, Max: st.max, Deadline: time.Now().Add(deadline)}
+	if err := saveTopUp(s); err != nil {
+		return err
+	}
+	if err := setThresholds(0, 100, sourceTopUp); err != nil {
+		clearTopUp()
+		return err
+	}
+	logInfo.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "TopUpStarted", Other: "Charging fully once, thresholds {{.Min}}%-{{.Max}}% will be restored when the battery is full or at {{.Deadline}}"}, TemplateData: map[string]interface{}{"Min": s.Min, "Max": s.Max, "Deadline": s.Deadline.Format("15:04")}}))
+	stateChanged()
+	return nil
+}
+
+// cancelTopUp restores the thresholds right away
+func cancelTopUp() error {
+	topUpMutex.Lock()
+	defer topUpMutex.Unlock()
+	s, err := loadTopUp()
+	if err != nil || s == nil {
+		return err
+	}
+	if err := setThresholds(s.Min, s.Max, sourceTopUp); err != nil {
+		return err
+	}
+	clearTopUp()
+	logInfo.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "TopUpCancelled", Other: "Full charge cancelled, thresholds restored"}}))
+	stateChanged()
+	return nil
+}
+
+// toggleTopUp starts the top-up or cancels the one in progress
+func toggleTopUp() error {
+	if topUpInProgress() != nil {
+		return cancelTopUp()
+	}
+	return startTopUp()
+}
+
+// checkTopUp restores the thresholds once the battery is full or the
+// deadline has passed, telling whether the top-up is over and returning the
+// message to notify the user with (if any); it must only be run in the
+// hardware goroutine
+func checkTopUp(s topUpState, now time.Time) (over bool, msg string, err error) {
+	if config.thresh == nil {
+		// the driver may show up later
+		return false, "", nil
+	}
+	min, max, err := config.thresh.get()
+	if err != nil {
+		return false, "", err
+	}
+	if !sameThresholds(min, max, 0, 100) {
+		logInfo.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "TopUpOverridden", Other: "Thresholds were changed while charging fully, not restoring the previous ones"}}))
+		return true, "", nil
+	}
+
+	bat, batErr := getBattery()
+	full := batErr == nil && bat.full()
+	if !full && now.Before(s.Deadline) {
+		return false, "", nil
+	}
+	if err := writeThresholds(s.Min, s.Max, sourceTopUp); err != nil {
+		return false, "", err
+	}
+	updateStatus()
+	if full {
+		msg = localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "TopUpDone", Other: "Battery is charged fully, thresholds {{.Min}}%-{{.Max}}% restored"}, TemplateData: map[string]interface{}{"Min": s.Min, "Max": s.Max}})
+	} else {
+		msg = localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "TopUpTimedOut", Other: "Battery hasn't charged fully in time, thresholds {{.Min}}%-{{.Max}}% restored"}, TemplateData: map[string]interface{}{"Min": s.Min, "Max": s.Max}})
+	}
+	return true, msg, nil
+}
+
+// watchTopUp checks on the top-up in progress (if any), including the one
+// started before the applet was restarted
+func watchTopUp() {
+	if s := topUpInProgress(); s != nil {
+		logInfo.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "TopUpResumed", Other: "Continuing to charge fully, thresholds {{.Min}}%-{{.Max}}% will be restored"}, TemplateData: map[string]interface{}{"Min": s.Min, "Max": s.Max}}))
+	}
+	for {
+		topUpTick()
+		time.Sleep(topUpCheckInterval)
+	}
+}
+
+func topUpTick() {
+	topUpMutex.Lock()
+	defer topUpMutex.Unlock()
+	s, err := loadTopUp()
+	if err != nil {
+		logTrace.Println(err)
+		return
+	}
+	if s == nil {
+		return
+	}
+	var over bool
+	var msg string
+	hwDo(func() { over, msg, err = checkTopUp(*s, time.Now()) })
+	if err != nil {
+		logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantRestoreAfterTopUp", Other: "Failed to restore thresholds after charging fully: {{.Error}}"}, TemplateData: map[string]interface{}{"Error": err}}))
+		return
+	}
+	if !over {
+		return
+	}
+	clearTopUp()
+	if msg != "" {
+		logInfo.Println(msg)
+		sendNotification(eventTopUp, msg)
+	}
+	stateChanged()
+}
+
+// topUpTitle returns the title for the UI element that starts or cancels
+// the top-up
+func topUpTitle() string {
+	if s := topUpInProgress(); s != nil {
+		return localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "TopUpCancel", Other: "Charging fully until {{.Deadline}} (click to cancel)"}, TemplateData: map[string]interface{}{"Deadline": s.Deadline.Format("15:04")}})
+	}
+	return localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "TopUpStart", Other: "Charge fully once"}})
+}
@@ -0,0 +1,68 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTopUpState(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	if s, err := loadTopUp(); s != nil || err != nil {
+		t.Fatalf("want no top-up, got: %v, %v", s, err)
+	}
+	want := topUpState{Min: 40, Max: 70, Deadline: time.Now().Add(time.Hour).Round(time.Second)}
+	if err := saveTopUp(want); err != nil {
+		t.Fatal(err)
+	}
+	s, err := loadTopUp()
+	if err != nil || s == nil || s.Min != want.Min || s.Max != want.Max || !s.Deadline.Equal(want.Deadline) {
+		t.Fatalf("want: %v, got: %v, %v", want, s, err)
+	}
+	clearTopUp()
+	if s := topUpInProgress(); s != nil {
+		t.Fatalf("top-up not cleared: %v", s)
+	}
+}
+
+func TestCheckTopUp(t *testing.T) {
+	settings.Conflicts.RevertDelay = 0
+	defer func() { settings = defaultSettings() }()
+	drv := &mockDriver{0, 100}
+	config.thresh, config.threshWritable = threshDriver{drv}, true
+	defer func() { config.thresh, config.threshWritable = nil, false }()
+
+	s := topUpState{Min: 40, Max: 70, Deadline: time.Now().Add(-time.Minute)}
+	over, msg, err := checkTopUp(s, time.Now())
+	if err != nil || !over || msg == "" {
+		t.Fatalf("not restored after deadline: %v, %q, %v", over, msg, err)
+	}
+	if drv.vMin != 40 || drv.vMax != 70 {
+		t.Fatalf("want: 40-70, got: %d-%d", drv.vMin, drv.vMax)
+	}
+
+	// thresholds set by the user while charging fully stay
+	drv.vMin, drv.vMax = 70, 90
+	over, msg, err = checkTopUp(s, time.Now())
+	if err != nil || !over || msg != "" {
+		t.Fatalf("want over silently, got: %v, %q, %v", over, msg, err)
+	}
+	if drv.vMin != 70 || drv.vMax != 90 {
+		t.Fatalf("want: 70-90, got: %d-%d", drv.vMin, drv.vMax)
+	}
+}
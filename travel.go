@@ -0,0 +1,254 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	defaultTravelTag      = "travel"
+	defaultTravelPreset   = "travel"
+	defaultTravelLeadTime = 12 * time.Hour
+	travelCheckInterval   = time.Minute
+	travelStateFile       = "travel.json"
+)
+
+// travelSettings define how the calendar is used to switch to travel mode
+type travelSettings struct {
+	Calendar     string        `toml:"calendar"`
+	Tag          string        `toml:"tag"`
+	Preset       string        `toml:"preset"`
+	NormalPreset string        `toml:"normal_preset"`
+	LeadTime     time.Duration `toml:"lead_time"`
+}
+
+// travelState is the travel mode the applet has switched to, it is kept in
+// a file so that the thresholds are switched back after an applet restart
+type travelState struct {
+	Min   int    `json:"min"`
+	Max   int    `json:"max"`
+	Event string `json:"event"`
+}
+
+// travelPlan is when the thresholds are to be switched next
+type travelPlan struct {
+	active bool
+	event  string
+	next   time.Time
+}
+
+var plannedTravel struct {
+	sync.Mutex
+	travelPlan
+	ok bool
+}
+
+// travelEvent tells whether the event is tagged for travel
+func travelEvent(ev icsEvent, tag string) bool {
+	tag = strings.ToLower(tag)
+	for _, s := range []string{ev.summary, ev.categories, ev.description} {
+		if strings.Contains(strings.ToLower(s), tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// planTravel tells whether the thresholds should be in travel mode now and
+// when they are to be switched next; travel mode starts lead before the
+// event and lasts until the end of it (or of the overlapping ones)
+func planTravel(events []icsEvent, tag string, lead time.Duration, now time.Time) travelPlan {
+	type window struct {
+		from, to time.Time
+		event    string
+	}
+	var windows []window
+	for _, ev := range events {
+		if travelEvent(ev, tag) && ev.end.After(now) {
+			windows = append(windows, window{ev.start.Add(-lead), ev.end, ev.summary})
+		}
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i].from.Before(windows[j].from) })
+
+	var p travelPlan
+	for _, w := range windows {
+		switch {
+		case p.active && !w.from.After(p.next):
+			// overlaps the current one
+			if w.to.After(p.next) {
+				p.next = w.to
+			}
+		case p.active:
+			return p
+		case !w.from.After(now):
+			p = travelPlan{active: true, event: w.event, next: w.to}
+		default:
+			return travelPlan{event: w.event, next: w.from}
+		}
+	}
+	return p
+}
+
+// travelCalendar returns the path of the calendar, expanding ~
+func travelCalendar() string {
+	path := settings.Travel.Calendar
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
+
+// watchTravel switches the thresholds to travel mode before the travel
+// events in the calendar and back after them
+func watchTravel() {
+	if settings.Travel.Calendar == "" {
+		return
+	}
+	logTrace.Println("will check the calendar", travelCalendar(), "for travel")
+	for {
+		travelTick(time.Now())
+		time.Sleep(travelCheckInterval)
+	}
+}
+
+func travelTick(now time.Time) {
+	events, err := readCalendar(travelCalendar())
+	if err != nil {
+		logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantReadCalendar", Other: "Failed to read the calendar: {{.Error}}"}, TemplateData: map[string]interface{}{"Error": err}}))
+		return
+	}
+	tag := settings.Travel.Tag
+	if tag == "" {
+		tag = defaultTravelTag
+	}
+	lead := settings.Travel.LeadTime
+	if lead < 0 {
+		lead = defaultTravelLeadTime
+	}
+	p := planTravel(events, tag, lead, now)
+
+	plannedTravel.Lock()
+	changed := !plannedTravel.ok || plannedTravel.travelPlan != p
+	plannedTravel.travelPlan, plannedTravel.ok = p, true
+	plannedTravel.Unlock()
+
+	var s travelState
+	active, err := readState(travelStateFile, &s)
+	if err != nil {
+		logTrace.Println(err)
+		return
+	}
+	if p.active != active {
+		if topUpInProgress() != nil || calibrationInProgress() != nil {
+			logTrace.Println("not switching travel mode while charging fully")
+			return
+		}
+		var msg string
+		if p.active {
+			msg, err = startTravel(p.event)
+		} else {
+			msg, err = endTravel(s)
+		}
+		if err != nil {
+			logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantSwitchTravel", Other: "Failed to switch travel mode: {{.Error}}"}, TemplateData: map[string]interface{}{"Error": err}}))
+			return
+		}
+		logInfo.Println(msg)
+		sendNotification(eventTravel, msg)
+		changed = true
+	}
+	if changed {
+		stateChanged()
+	}
+}
+
+// travelPreset returns the name of the preset to apply for travel
+func travelPreset() string {
+	if name := settings.Travel.Preset; name != "" {
+		return name
+	}
+	return defaultTravelPreset
+}
+
+// startTravel remembers the thresholds and applies the travel preset
+func startTravel(event string) (string, error) {
+	refreshStatus()
+	st := cachedStatus()
+	if !st.thresh || !st.threshWritable || st.threshErr != nil {
+		return "", errNotAvailable()
+	}
+	name := travelPreset()
+	if err := writeState(travelStateFile, travelState{Min: st.min, Max: st.max, Event: event}); err != nil {
+		return "", err
+	}
+	if err := applyPreset(name, sourceSchedule); err != nil {
+		removeState(travelStateFile)
+		return "", err
+	}
+	return localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "TravelStarted", Other: "Switched to {{.Preset}} for {{.Event}}"}, TemplateData: map[string]interface{}{"Preset": strings.ToUpper(name), "Event": event}}), nil
+}
+
+// endTravel applies the normal preset, or the thresholds that were set
+// before travel mode, unless the thresholds were changed during the travel
+func endTravel(s travelState) (string, error) {
+	refreshStatus()
+	st := cachedStatus()
+	if !st.thresh || !st.threshWritable || st.threshErr != nil {
+		return "", errNotAvailable()
+	}
+	if p, ok := findPreset(travelPreset()); !ok || !sameThresholds(st.min, st.max, p.min, p.max) {
+		removeState(travelStateFile)
+		return localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "TravelEndedOverridden", Other: "{{.Event}} is over, thresholds were changed during it and are left as they are"}, TemplateData: map[string]interface{}{"Event": s.Event}}), nil
+	}
+	var err error
+	if name := settings.Travel.NormalPreset; name != "" {
+		err = applyPreset(name, sourceSchedule)
+	} else {
+		err = setThresholds(s.Min, s.Max, sourceSchedule)
+		stateChanged()
+	}
+	if err != nil {
+		return "", err
+	}
+	removeState(travelStateFile)
+	return localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "TravelEnded", Other: "{{.Event}} is over, battery protection is back to normal: {{.Status}}"}, TemplateData: map[string]interface{}{"Event": s.Event, "Status": getStatus()}}), nil
+}
+
+// travelStatus describes the next planned switch for the UI, or returns
+// an empty string if there's none
+func travelStatus() string {
+	plannedTravel.Lock()
+	p, ok := plannedTravel.travelPlan, plannedTravel.ok
+	plannedTravel.Unlock()
+	if !ok || p.next.IsZero() {
+		return ""
+	}
+	when := p.next.Format("Mon Jan 2 15:04")
+	if p.active {
+		return localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "TravelUntil", Other: "Travel mode until {{.When}} ({{.Event}})"}, TemplateData: map[string]interface{}{"When": when, "Event": p.event}})
+	}
+	return localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "TravelNext", Other: "Travel mode from {{.When}} ({{.Event}})"}, TemplateData: map[string]interface{}{"When": when, "Event": p.event}})
+}
@@ -0,0 +1,98 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlanTravel(t *testing.T) {
+	day := time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)
+	events := []icsEvent{
+		{summary: "Flight", categories: "Travel", start: day.Add(8 * time.Hour), end: day.Add(12 * time.Hour)},
+		{summary: "Train", start: day.Add(11 * time.Hour), end: day.Add(15 * time.Hour), description: "travel back"},
+		{summary: "Meeting", start: day.Add(16 * time.Hour), end: day.Add(17 * time.Hour)},
+		{summary: "Trip", categories: "TRAVEL", start: day.Add(48 * time.Hour), end: day.Add(50 * time.Hour)},
+	}
+	lead := 2 * time.Hour
+
+	tests := []struct {
+		now  time.Time
+		want travelPlan
+	}{
+		{day, travelPlan{event: "Flight", next: day.Add(6 * time.Hour)}},
+		{day.Add(7 * time.Hour), travelPlan{active: true, event: "Flight", next: day.Add(15 * time.Hour)}},
+		{day.Add(15 * time.Hour), travelPlan{event: "Trip", next: day.Add(46 * time.Hour)}},
+		{day.Add(60 * time.Hour), travelPlan{}},
+	}
+	for _, tc := range tests {
+		if got := planTravel(events, "travel", lead, tc.now); got != tc.want {
+			t.Errorf("at %v: want %+v, got %+v", tc.now, tc.want, got)
+		}
+	}
+}
+
+func TestEndTravel(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	drv := &mockDriver{vMin: 40, vMax: 70}
+	config.thresh = threshDriver{drv}
+	config.threshWritable = true
+	defer func() {
+		config.thresh, config.threshWritable = nil, false
+		undoStack.entries = nil
+	}()
+	travel, _ := findPreset(defaultTravelPreset)
+
+	if _, err := startTravel("Flight"); err != nil {
+		t.Fatal(err)
+	}
+	if drv.vMin != travel.min || drv.vMax != travel.max {
+		t.Fatalf("want: %d-%d, got: %d-%d", travel.min, travel.max, drv.vMin, drv.vMax)
+	}
+	var s travelState
+	if _, err := readState(travelStateFile, &s); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := endTravel(s); err != nil {
+		t.Fatal(err)
+	}
+	if drv.vMin != 40 || drv.vMax != 70 {
+		t.Fatalf("not restored, got: %d-%d", drv.vMin, drv.vMax)
+	}
+	if active, _ := readState(travelStateFile, &s); active {
+		t.Fatal("travel state not cleared")
+	}
+
+	if _, err := startTravel("Flight"); err != nil {
+		t.Fatal(err)
+	}
+	if err := setThresholds(50, 60, sourceUser); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readState(travelStateFile, &s); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := endTravel(s); err != nil {
+		t.Fatal(err)
+	}
+	if drv.vMin != 50 || drv.vMax != 60 {
+		t.Fatalf("changed thresholds overwritten, got: %d-%d", drv.vMin, drv.vMax)
+	}
+	if active, _ := readState(travelStateFile, &s); active {
+		t.Fatal("travel state not cleared")
+	}
+}
@@ -16,6 +16,8 @@
 package main
 
 import (
+	"strings"
+
 	"github.com/andlabs/ui"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
 )
@@ -24,135 +26,212 @@ var (
 	kbdlightTimeoutWindow *ui.Window
 	customWindow          *ui.Window
 	mainWindow            *ui.Window
+	refreshWindow         = func() {}
 )
 
+// showWindow brings up the main window, creating it if needed
+func showWindow() {
+	ui.QueueMain(func() {
+		if mainWindow != nil {
+			mainWindow.Show()
+			return
+		}
+		launchUI()
+	})
+}
+
 func launchUI() {
 	logTrace.Println("Setting up GUI...")
 	mainWindow = ui.NewWindow("matebook-applet", 480, 360, false)
 	mainWindow.OnClosing(func(*ui.Window) bool {
-		ui.Quit()
-		return true
-	})
-	ui.OnShouldQuit(func() bool {
-		customWindow.Destroy()
-		mainWindow.Destroy()
+		if config.windowed {
+			ui.Quit()
+		} else {
+			// the tray applet keeps running
+			mainWindow = nil
+			refreshWindow = func() {}
+		}
 		return true
 	})
+	if config.windowed {
+		ui.OnShouldQuit(func() bool {
+			customWindow.Destroy()
+			mainWindow.Destroy()
+			return true
+		})
+	}
 
 	mainWindow.SetMargined(true)
 	vbox := ui.NewVerticalBox()
 	vbox.SetPadded(true)
 	mainWindow.SetChild(vbox)
 
+	nothingLabel := ui.NewLabel(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "NothingFound", Other: "No supported hardware found"}}))
+	vbox.Append(nothingLabel, false)
+
+	policyLabel := ui.NewLabel(policy.lockedReason())
+	vbox.Append(policyLabel, false)
+
+	conflictGroup := ui.NewGroup("")
+	conflictGroup.SetMargined(true)
+	vbox.Append(conflictGroup, false)
+	conflictVbox := ui.NewVerticalBox()
+	conflictVbox.SetPadded(true)
+	conflictGroup.SetChild(conflictVbox)
+	conflictLabel := ui.NewLabel("")
+	conflictVbox.Append(conflictLabel, false)
+	takeOverButton := ui.NewButton(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "TakeOver", Other: "Take over"}}))
+	takeOverButton.OnClicked(func(*ui.Button) {
+		logTrace.Println("Take over button clicked")
+		inBackground(takeOver)
+	})
+	conflictVbox.Append(takeOverButton, false)
+
 	kbdlightTimeoutGroup := ui.NewGroup("")
 	kbdlightTimeoutGroup.SetMargined(true)
-	if config.kdblightTimeout == nil {
-		logTrace.Println("no access to kbdlight_timeout setting, not showing its GUI")
-	} else {
-		vbox.Append(kbdlightTimeoutGroup, false)
-		kbdlightTimeoutGroup.SetTitle(getKbdlightTimeoutStatus())
-		kbdlightTimeoutVbox := ui.NewVerticalBox()
-		kbdlightTimeoutVbox.SetPadded(true)
-		kbdlightTimeoutGroup.SetChild(kbdlightTimeoutVbox)
-
-		kbdlightTimeoutButton := ui.NewButton(localizer.MustLocalize(&i18n.LocalizeConfig{
-			DefaultMessage: &i18n.Message{ID: "ChangeValue", Other: "Change"},
-		}))
-
-		var kbdlightTimeoutOnClicked func(*ui.Button)
-		kbdlightTimeoutOnClicked = func(*ui.Button) {
-			logTrace.Println("Custom button clicked")
-			go func() {
-				kbdlightTimeoutButton.OnClicked(func(*ui.Button) {})
-				ch := make(chan struct{})
-				ui.QueueMain(func() { kbdlightTimeout(ch) })
-				<-ch
-				kbdlightTimeoutGroup.SetTitle(getKbdlightTimeoutStatus())
-				kbdlightTimeoutButton.OnClicked(kbdlightTimeoutOnClicked)
-			}()
-		}
-		kbdlightTimeoutButton.OnClicked(kbdlightTimeoutOnClicked)
+	vbox.Append(kbdlightTimeoutGroup, false)
+	kbdlightTimeoutVbox := ui.NewVerticalBox()
+	kbdlightTimeoutVbox.SetPadded(true)
+	kbdlightTimeoutGroup.SetChild(kbdlightTimeoutVbox)
 
-		kbdlightTimeoutVbox.Append(kbdlightTimeoutButton, false)
+	kbdlightTimeoutButton := ui.NewButton(localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{ID: "ChangeValue", Other: "Change"},
+	}))
 
-		if !config.kdblightTimeout.isWritable() {
-			kbdlightTimeoutButton.Disable()
-		}
+	var kbdlightTimeoutOnClicked func(*ui.Button)
+	kbdlightTimeoutOnClicked = func(*ui.Button) {
+		logTrace.Println("Custom button clicked")
+		go func() {
+			kbdlightTimeoutButton.OnClicked(func(*ui.Button) {})
+			ch := make(chan error, 1)
+			ui.QueueMain(func() { kbdlightTimeout(ch) })
+			<-ch
+			ui.QueueMain(func() {
+				refreshWindow()
+				kbdlightTimeoutButton.OnClicked(kbdlightTimeoutOnClicked)
+			})
+		}()
 	}
+	kbdlightTimeoutButton.OnClicked(kbdlightTimeoutOnClicked)
+
+	kbdlightTimeoutVbox.Append(kbdlightTimeoutButton, false)
 
 	batteryGroup := ui.NewGroup("")
 	batteryGroup.SetMargined(true)
-	if config.thresh == nil {
-		logTrace.Println("no access to BP information, not showing the corresponding UI")
-	} else {
-		vbox.Append(batteryGroup, false)
-		batteryGroup.SetTitle(getStatus())
-	}
+	vbox.Append(batteryGroup, false)
+
+	batteryOuterVbox := ui.NewVerticalBox()
+	batteryOuterVbox.SetPadded(true)
+	batteryGroup.SetChild(batteryOuterVbox)
+
+	estimateLabel := ui.NewLabel("")
+	batteryOuterVbox.Append(estimateLabel, false)
 
 	batteryVbox := ui.NewVerticalBox()
 	batteryVbox.SetPadded(true)
-	if config.thresh != nil && config.thresh.isWritable() {
-		batteryGroup.SetChild(batteryVbox)
-	} else {
-		logTrace.Println("BP endpoint read-only, not showing BP buttons")
-	}
+	batteryOuterVbox.Append(batteryVbox, false)
 
 	offButton := ui.NewButton(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "SetOff", Other: "Off"}}))
 	offButton.OnClicked(func(*ui.Button) {
 		logTrace.Println("Off button clicked")
-		setThresholds(0, 100)
-		batteryGroup.SetTitle(getStatus())
+		inBackground(func() error { return applyPreset("off", sourceUser) })
 	})
 	batteryVbox.Append(offButton, false)
 
 	travelButton := ui.NewButton(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "SetTravel", Other: "Travel"}}))
 	travelButton.OnClicked(func(*ui.Button) {
 		logTrace.Println("Travel button clicked")
-		setThresholds(95, 100)
-		batteryGroup.SetTitle(getStatus())
+		inBackground(func() error { return applyPreset("travel", sourceUser) })
 	})
 	batteryVbox.Append(travelButton, false)
 
 	officeButton := ui.NewButton(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "SetOffice", Other: "Office"}}))
 	officeButton.OnClicked(func(*ui.Button) {
 		logTrace.Println("Office button clicked")
-		setThresholds(70, 90)
-		batteryGroup.SetTitle(getStatus())
+		inBackground(func() error { return applyPreset("office", sourceUser) })
 	})
 	batteryVbox.Append(officeButton, false)
 
 	homeButton := ui.NewButton(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "SetHome", Other: "Home"}}))
 	homeButton.OnClicked(func(*ui.Button) {
 		logTrace.Println("Home button clicked")
-		setThresholds(40, 70)
-		batteryGroup.SetTitle(getStatus())
+		inBackground(func() error { return applyPreset("home", sourceUser) })
 	})
 	batteryVbox.Append(homeButton, false)
 
+	// the presets from the configuration file, by name
+	presetButtons := map[ui.Control]string{}
+	for _, p := range customPresets() {
+		name := p.name
+		button := ui.NewButton(presetTitle(p))
+		button.OnClicked(func(*ui.Button) {
+			logTrace.Println(strings.ToUpper(name), "button clicked")
+			inBackground(func() error { return applyPreset(name, sourceUser) })
+		})
+		batteryVbox.Append(button, false)
+		presetButtons[button] = name
+	}
+
 	customButton := ui.NewButton(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "SetCustom", Other: "Custom"}}))
 	var customButtonOnClicked func(*ui.Button)
 	customButtonOnClicked = func(*ui.Button) {
 		logTrace.Println("Custom button clicked")
 		go func() {
 			customButton.OnClicked(func(*ui.Button) {})
-			ch := make(chan struct{})
+			ch := make(chan error, 1)
 			ui.QueueMain(func() { customThresholds(ch) })
 			<-ch
-			batteryGroup.SetTitle(getStatus())
-			customButton.OnClicked(customButtonOnClicked)
+			ui.QueueMain(func() {
+				refreshWindow()
+				customButton.OnClicked(customButtonOnClicked)
+			})
 		}()
 	}
 	customButton.OnClicked(customButtonOnClicked)
 	batteryVbox.Append(customButton, false)
 
+	topUpButton := ui.NewButton("")
+	topUpButton.OnClicked(func(*ui.Button) {
+		logTrace.Println("Charge fully once button clicked")
+		inBackground(toggleTopUp)
+	})
+	batteryVbox.Append(topUpButton, false)
+
+	calibrateButton := ui.NewButton("")
+	calibrateButton.OnClicked(func(*ui.Button) {
+		logTrace.Println("Calibrate battery button clicked")
+		inBackground(toggleCalibration)
+	})
+	batteryVbox.Append(calibrateButton, false)
+
+	travelLabel := ui.NewLabel("")
+	batteryVbox.Append(travelLabel, false)
+
+	suggestLabel := ui.NewLabel("")
+	batteryVbox.Append(suggestLabel, false)
+	suggestButton := ui.NewButton("")
+	suggestButton.OnClicked(func(*ui.Button) {
+		logTrace.Println("Suggested thresholds button clicked")
+		inBackground(applyRecommendation)
+	})
+	batteryVbox.Append(suggestButton, false)
+
+	healthButton := ui.NewButton("")
+	healthButton.OnClicked(func(*ui.Button) {
+		logTrace.Println("Health warning button clicked")
+		inBackground(applyHealthPreset)
+	})
+	batteryVbox.Append(healthButton, false)
+	healthDismissButton := ui.NewButton(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "DismissWarning", Other: "Dismiss the warning"}}))
+	healthDismissButton.OnClicked(func(*ui.Button) {
+		logTrace.Println("Dismiss health warning button clicked")
+		inBackground(dismissHealthWarning)
+	})
+	batteryVbox.Append(healthDismissButton, false)
+
 	fnlockGroup := ui.NewGroup("")
 	fnlockGroup.SetMargined(true)
-	if config.fnlock == nil {
-		logTrace.Println("no access to Fn-Lock setting, not showing the corresponding GUI")
-	} else {
-		vbox.Append(fnlockGroup, false)
-		fnlockGroup.SetTitle(getFnlockStatus())
-	}
+	vbox.Append(fnlockGroup, false)
 
 	fnlockVbox := ui.NewVerticalBox()
 	fnlockVbox.SetPadded(true)
@@ -161,26 +240,113 @@ func launchUI() {
 	fnlockToggle := ui.NewButton(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "DoToggle", Other: "Toggle"}}))
 	fnlockToggle.OnClicked(func(*ui.Button) {
 		logTrace.Println("Fnlock toggle button clicked")
-		config.fnlock.toggle()
-		fnlockGroup.SetTitle(getFnlockStatus())
+		inBackground(func() error { return toggleFnlock(sourceUser) })
 	})
-	if config.fnlock != nil && config.fnlock.isWritable() {
-		fnlockVbox.Append(fnlockToggle, false)
-	} else {
-		logTrace.Println("Fn-Lock setting read-only, not showing the button")
+	fnlockVbox.Append(fnlockToggle, false)
+
+	undoButton := ui.NewButton("")
+	undoButton.OnClicked(func(*ui.Button) {
+		logTrace.Println("Undo button clicked")
+		inBackground(undoLast)
+	})
+	vbox.Append(undoButton, false)
+
+	// the groups for the endpoints that are not available (yet) are hidden
+	refreshWindow = func() {
+		s := cachedStatus()
+		showControl(nothingLabel, nothingFound())
+		showControl(policyLabel, policy.active())
+		found := currentConflicts()
+		showControl(conflictGroup, len(found) > 0)
+		if len(found) > 0 {
+			conflictGroup.SetTitle(conflictsTitle(found))
+			conflictLabel.SetText(conflictsDetails(found))
+			showControl(takeOverButton, s.thresh && s.threshWritable)
+		}
+		showControl(kbdlightTimeoutGroup, s.kdblightTimeout)
+		if s.kdblightTimeout {
+			kbdlightTimeoutGroup.SetTitle(getKbdlightTimeoutStatus())
+			enableControl(kbdlightTimeoutButton, s.kdblightTimeoutWritable && policy.KbdlightTimeout == nil)
+		}
+		showControl(batteryGroup, s.thresh)
+		showControl(batteryVbox, s.thresh && s.threshWritable)
+		if s.thresh {
+			batteryGroup.SetTitle(getStatus())
+			estimate := chargeEstimate()
+			showControl(estimateLabel, estimate != "")
+			estimateLabel.SetText(estimate)
+			for c, name := range map[ui.Control]string{offButton: "off", travelButton: "travel", officeButton: "office", homeButton: "home", customButton: "custom"} {
+				enableControl(c, policy.checkPreset(name) == nil)
+			}
+			for c, name := range presetButtons {
+				enableControl(c, policy.checkPreset(name) == nil)
+			}
+			enableControl(topUpButton, policy.checkThresholds(0, 100) == nil)
+			enableControl(calibrateButton, policy.checkThresholds(0, 100) == nil)
+			topUpButton.SetText(topUpTitle())
+			calibrateButton.SetText(calibrationTitle())
+		}
+		travel := travelStatus()
+		showControl(travelLabel, travel != "")
+		travelLabel.SetText(travel)
+		rec := currentRecommendation()
+		showControl(suggestLabel, rec != nil)
+		showControl(suggestButton, rec != nil)
+		if rec != nil {
+			suggestLabel.SetText(rec.reason)
+			suggestButton.SetText(recommendationTitle(rec))
+		}
+		since, warn := currentHealthWarning()
+		showControl(healthButton, warn)
+		showControl(healthDismissButton, warn)
+		if warn {
+			healthButton.SetText(healthTitle(since))
+		}
+		showControl(fnlockGroup, s.fnlock)
+		showControl(fnlockToggle, s.fnlock && s.fnlockWritable)
+		if s.fnlock {
+			fnlockGroup.SetTitle(getFnlockStatus())
+			enableControl(fnlockToggle, policy.checkFnlock(!s.fnlockState) == nil)
+		}
+		undo, ok := lastUndo()
+		showControl(undoButton, ok)
+		if ok {
+			undoButton.SetText(undoTitle(undo))
+			enableControl(undoButton, undo.allowed() == nil)
+		}
 	}
+	refreshWindow()
 
 	mainWindow.Show()
 }
 
-func customThresholds(ch chan struct{}) {
+// customThresholds shows the window to set custom thresholds, the result
+// is sent to ch when the window is closed
+func customThresholds(ch chan error) {
 	logTrace.Println("Launching custom thresholds window")
-	min, max, err := config.thresh.get()
+	s := cachedStatus()
+	if !s.thresh {
+		ch <- errNotAvailable()
+		close(ch)
+		return
+	}
+	if err := policy.checkPreset("custom"); err != nil {
+		ch <- err
+		close(ch)
+		return
+	}
+	min, max, err := s.min, s.max, s.threshErr
 	if err != nil {
 		logWarning.Println(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantReadBattery", Other: "failed to get thresholds"}}))
 	}
 	customWindow = ui.NewWindow(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CustomWindowTitle", Other: "Charging thresholds"}}), 640, 240, false)
+	// both callbacks run in the GUI thread, so the flag needs no locking
+	writing := false
 	customWindow.OnClosing(func(*ui.Window) bool {
+		// the result is reported once the write is done
+		if writing {
+			return false
+		}
 		close(ch)
 		return true
 	})
@@ -190,8 +356,11 @@ func customThresholds(ch chan struct{}) {
 	hbox := ui.NewHorizontalBox()
 	hbox.SetPadded(true)
 	customWindow.SetChild(vbox)
-	minSlider := ui.NewSlider(0, 100)
-	maxSlider := ui.NewSlider(0, 100)
+	// the sliders only go as far as the policy allows
+	minLo, minHi := policy.minRange()
+	maxLo, maxHi := policy.maxRange()
+	minSlider := ui.NewSlider(minLo, minHi)
+	maxSlider := ui.NewSlider(maxLo, maxHi)
 	minSlider.OnChanged(func(*ui.Slider) {
 		if minSlider.Value() > maxSlider.Value() {
 			minSlider.SetValue(maxSlider.Value())
@@ -210,9 +379,20 @@ func customThresholds(ch chan struct{}) {
 	vbox.Append(maxLabel, false)
 	setButton := ui.NewButton(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "DoSet", Other: "Set"}}))
 	setButton.OnClicked(func(*ui.Button) {
-		setThresholds(minSlider.Value(), maxSlider.Value())
-		customWindow.Destroy()
-		close(ch)
+		setButton.Disable()
+		min, max := minSlider.Value(), maxSlider.Value()
+		writing = true
+		go func() {
+			err := setThresholds(min, max, sourceUser)
+			ui.QueueMain(func() {
+				if err != nil {
+					showError(customWindow, err)
+				}
+				customWindow.Destroy()
+				ch <- err
+				close(ch)
+			})
+		}()
 	})
 	vbox.Append(hbox, false)
 	hbox.Append(setButton, true)
@@ -221,9 +401,17 @@ func customThresholds(ch chan struct{}) {
 	customWindow.Show()
 }
 
-func kbdlightTimeout(ch chan struct{}) {
+// kbdlightTimeout shows the window to set keyboard light timeout, the result
+// is sent to ch when the window is closed
+func kbdlightTimeout(ch chan error) {
 	logTrace.Println("Launching custom kdblight_timeout window")
-	timeout, _ := config.kdblightTimeout.get()
+	s := cachedStatus()
+	if !s.kdblightTimeout {
+		ch <- errNotAvailable()
+		close(ch)
+		return
+	}
+	timeout := s.timeout
 
 	kbdlightTimeoutWindow = ui.NewWindow(localizer.MustLocalize(&i18n.LocalizeConfig{
 		DefaultMessage: &i18n.Message{
@@ -231,7 +419,13 @@ func kbdlightTimeout(ch chan struct{}) {
 			Other: "Keyboard Light Timeout",
 		},
 	}), 640, 100, false)
+	// both callbacks run in the GUI thread, so the flag needs no locking
+	writing := false
 	kbdlightTimeoutWindow.OnClosing(func(*ui.Window) bool {
+		// the result is reported once the write is done
+		if writing {
+			return false
+		}
 		close(ch)
 		return true
 	})
@@ -261,12 +455,61 @@ func kbdlightTimeout(ch chan struct{}) {
 		},
 	}))
 	setButton.OnClicked(func(*ui.Button) {
-		config.kdblightTimeout.set(timeoutSpinbox.Value())
-		kbdlightTimeoutWindow.Destroy()
-		close(ch)
+		setButton.Disable()
+		timeout := timeoutSpinbox.Value()
+		writing = true
+		go func() {
+			err := setKbdlightTimeout(timeout, sourceUser)
+			ui.QueueMain(func() {
+				if err != nil {
+					showError(kbdlightTimeoutWindow, err)
+				}
+				kbdlightTimeoutWindow.Destroy()
+				ch <- err
+				close(ch)
+			})
+		}()
 	})
 
 	vbox.Append(hbox, false)
 	hbox.Append(setButton, true)
 	kbdlightTimeoutWindow.Show()
 }
+
+// inBackground runs the request off the GUI thread, then shows the error
+// (if any) and the new state in the window
+func inBackground(req func() error) {
+	go func() {
+		err := req()
+		ui.QueueMain(func() {
+			if mainWindow == nil {
+				return
+			}
+			if err != nil {
+				showError(mainWindow, err)
+			}
+			refreshWindow()
+		})
+	}()
+}
+
+func showControl(c ui.Control, show bool) {
+	if show {
+		c.Show()
+	} else {
+		c.Hide()
+	}
+}
+
+func enableControl(c ui.Control, enable bool) {
+	if enable {
+		c.Enable()
+	} else {
+		c.Disable()
+	}
+}
+
+// showError tells the user that something went wrong
+func showError(w *ui.Window, err error) {
+	ui.MsgBoxError(w, localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "ErrorTitle", Other: "Error"}}), err.Error())
+}
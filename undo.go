@@ -0,0 +1,185 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	undoDepth = 10
+)
+
+// undoEntry is the value a setting had before it was changed and the
+// endpoint it was changed with
+type undoEntry struct {
+	setting  string
+	endpoint string
+	min, max int
+	fnlock   bool
+	timeout  int
+}
+
+var undoStack struct {
+	sync.Mutex
+	entries []undoEntry
+}
+
+// pushUndo remembers the previous value of a setting the user has just
+// changed (if it is known); a change made by the applet itself makes the
+// earlier changes of the setting impossible to undo. It must only be run in
+// the hardware goroutine
+func pushUndo(src changeSource, e undoEntry, known bool) {
+	if src == sourceUndo {
+		return
+	}
+	undoStack.Lock()
+	switch {
+	case known && (src == sourceUser || src == sourceCommand):
+		undoStack.entries = append(undoStack.entries, e)
+		if len(undoStack.entries) > undoDepth {
+			undoStack.entries = undoStack.entries[len(undoStack.entries)-undoDepth:]
+		}
+	default:
+		kept := undoStack.entries[:0]
+		for _, u := range undoStack.entries {
+			if u.setting != e.setting {
+				kept = append(kept, u)
+			}
+		}
+		undoStack.entries = kept
+	}
+	undoStack.Unlock()
+	stateChanged()
+}
+
+// lastUndo returns the change to be undone next
+func lastUndo() (undoEntry, bool) {
+	undoStack.Lock()
+	defer undoStack.Unlock()
+	if len(undoStack.entries) == 0 {
+		return undoEntry{}, false
+	}
+	return undoStack.entries[len(undoStack.entries)-1], true
+}
+
+// dropUndo forgets the change once it's undone, unless something else has
+// changed the undo stack in the meantime
+func dropUndo(e undoEntry) {
+	undoStack.Lock()
+	defer undoStack.Unlock()
+	n := len(undoStack.entries)
+	if n > 0 && undoStack.entries[n-1] == e {
+		undoStack.entries = undoStack.entries[:n-1]
+	}
+}
+
+// allowed tells whether the policy lets the change be undone
+func (e undoEntry) allowed() error {
+	switch e.setting {
+	case auditThresholds:
+		return policy.checkThresholds(e.min, e.max)
+	case auditFnlock:
+		return policy.checkFnlock(e.fnlock)
+	case auditKbdlightTimeout:
+		return policy.checkKbdlightTimeout(e.timeout)
+	}
+	return nil
+}
+
+// undoLast reverts the last change made by the user
+func undoLast() error {
+	var err error
+	hwDo(func() {
+		err = revertLast()
+		updateStatus()
+	})
+	stateChanged()
+	return err
+}
+
+func revertLast() error {
+	e, ok := lastUndo()
+	if !ok {
+		return nil
+	}
+	if err := revert(e); err != nil {
+		return err
+	}
+	dropUndo(e)
+	return nil
+}
+
+// revert sets the setting back to the value in the entry
+func revert(e undoEntry) error {
+	errEndpoint := errors.New(localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "CantUndoEndpoint", Other: "Can't undo: the setting is now handled by another endpoint"}}))
+	switch e.setting {
+	case auditThresholds:
+		if config.thresh == nil || fmt.Sprint(config.thresh) != e.endpoint {
+			return errEndpoint
+		}
+		return writeThresholds(e.min, e.max, sourceUndo)
+	case auditFnlock:
+		if config.fnlock == nil || fmt.Sprint(config.fnlock) != e.endpoint {
+			return errEndpoint
+		}
+		if live, err := config.fnlock.get(); err == nil && live == e.fnlock {
+			return nil
+		}
+		return writeFnlock(sourceUndo)
+	case auditKbdlightTimeout:
+		if config.kdblightTimeout == nil || fmt.Sprint(config.kdblightTimeout) != e.endpoint {
+			return errEndpoint
+		}
+		return writeKbdlightTimeout(e.timeout, sourceUndo)
+	}
+	return nil
+}
+
+// thresholdsName describes the thresholds, naming the preset they belong
+// to (if any)
+func thresholdsName(min, max int) string {
+	ids := map[string]string{"off": "StatusOff", "travel": "DoTravel", "office": "DoOffice", "home": "DoHome"}
+	for _, p := range presets {
+		if !sameThresholds(p.min, p.max, min, max) {
+			continue
+		}
+		if id, ok := ids[p.name]; ok {
+			return localizer.MustLocalize(&i18n.LocalizeConfig{MessageID: id})
+		}
+		return presetTitle(p)
+	}
+	return localizer.MustLocalize(&i18n.LocalizeConfig{MessageID: "StatusCustom", TemplateData: map[string]interface{}{"Min": min, "Max": max}})
+}
+
+// undoTitle returns the title for the UI element that undoes the change
+func undoTitle(e undoEntry) string {
+	switch e.setting {
+	case auditFnlock:
+		state := localizer.MustLocalize(&i18n.LocalizeConfig{MessageID: "StatusOff"})
+		if e.fnlock {
+			state = localizer.MustLocalize(&i18n.LocalizeConfig{MessageID: "StatusOn"})
+		}
+		return localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "UndoFnlock", Other: "Undo: Fn-Lock back to {{.State}}"}, TemplateData: map[string]interface{}{"State": state}})
+	case auditKbdlightTimeout:
+		return localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "UndoKbdlightTimeout", Other: "Undo: keyboard light timeout back to {{.Timeout}}s"}, TemplateData: map[string]interface{}{"Timeout": e.timeout}})
+	}
+	return localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "UndoThresholds", Other: "Undo: back to {{.Thresholds}}"}, TemplateData: map[string]interface{}{"Thresholds": thresholdsName(e.min, e.max)}})
+}
@@ -0,0 +1,85 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// namedDriver is a mock driver that is told apart by its name, as the real
+// endpoints are
+type namedDriver struct {
+	*mockDriver
+	name string
+}
+
+func (drv namedDriver) String() string {
+	return drv.name
+}
+
+func TestUndo(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	drv := &mockDriver{40, 70}
+	config.thresh, config.threshWritable = threshDriver{namedDriver{drv, "first"}}, true
+	defer func() {
+		config.thresh, config.threshWritable = nil, false
+		undoStack.entries = nil
+	}()
+
+	if err := setThresholds(95, 100, sourceUser); err != nil {
+		t.Fatal(err)
+	}
+	e, ok := lastUndo()
+	if !ok {
+		t.Fatal("nothing to undo")
+	}
+	if title := undoTitle(e); !strings.Contains(title, "HOME") {
+		t.Errorf("want undo back to HOME, got %q", title)
+	}
+	if err := undoLast(); err != nil {
+		t.Fatal(err)
+	}
+	if drv.vMin != 40 || drv.vMax != 70 {
+		t.Fatalf("want: 40-70, got: %d-%d", drv.vMin, drv.vMax)
+	}
+	if _, ok := lastUndo(); ok {
+		t.Error("undo itself can be undone")
+	}
+
+	// a change made by the applet itself can't be undone and makes the
+	// earlier ones stale
+	setThresholds(70, 90, sourceUser)
+	setThresholds(0, 100, sourceTopUp)
+	if _, ok := lastUndo(); ok {
+		t.Error("stale change can be undone")
+	}
+
+	for i := 0; i < undoDepth+5; i++ {
+		setThresholds(50+i, 90, sourceUser)
+	}
+	if n := len(undoStack.entries); n != undoDepth {
+		t.Errorf("want %d changes remembered, got %d", undoDepth, n)
+	}
+
+	config.thresh = threshDriver{namedDriver{drv, "second"}}
+	if err := undoLast(); err == nil {
+		t.Error("change undone with another endpoint")
+	}
+	if n := len(undoStack.entries); n != undoDepth {
+		t.Errorf("failed undo forgotten, want %d changes remembered, got %d", undoDepth, n)
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	usageFile           = "usage.jsonl"
+	usageSampleInterval = 10 * time.Minute
+	usageKeep           = 30 * 24 * time.Hour
+
+	// a recommendation needs this much history
+	usageMinHistory = 7 * 24 * time.Hour
+)
+
+// usageSample is whether the laptop was plugged in at some moment
+type usageSample struct {
+	Time     time.Time `json:"time"`
+	AC       bool      `json:"ac"`
+	Capacity int       `json:"capacity"`
+}
+
+// recommendation is the thresholds suggested from the usage history
+type recommendation struct {
+	preset preset
+	reason string
+}
+
+var recommended struct {
+	sync.Mutex
+	rec *recommendation
+}
+
+// loadUsage reads the usage history, skipping the samples that are too
+// old or can't be parsed
+func loadUsage(now time.Time) ([]usageSample, error) {
+	path, err := statePath(usageFile)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var samples []usageSample
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var s usageSample
+		if err := json.Unmarshal(sc.Bytes(), &s); err != nil {
+			continue
+		}
+		if now.Sub(s.Time) < usageKeep {
+			samples = append(samples, s)
+		}
+	}
+	return samples, sc.Err()
+}
+
+// saveUsage replaces the usage history with the samples given
+func saveUsage(samples []usageSample) error {
+	path, err := statePath(usageFile)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, s := range samples {
+		if err := enc.Encode(s); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// appendUsage adds a sample to the usage history
+func appendUsage(s usageSample) error {
+	return appendState(usageFile, s)
+}
+
+// watchUsage samples whether the laptop is plugged in and updates the
+// recommendation and the health warning, only the former needs the
+// samples to be kept in the usage history
+func watchUsage() {
+	history := settings.UsageHistory
+	if !history && settings.Health.FullDays <= 0 {
+		return
+	}
+	var samples []usageSample
+	if history {
+		var err error
+		if samples, err = loadUsage(time.Now()); err != nil {
+			logTrace.Println(err)
+		}
+		// drop the samples that are too old
+		if err := saveUsage(samples); err != nil {
+			logTrace.Println(err)
+		}
+		updateRecommendation(samples)
+	}
+	updateHealth(samples, time.Now())
+
+	for range time.Tick(usageSampleInterval) {
+		ac, err := acOnline()
+		if err != nil {
+			logTrace.Println(err)
+			continue
+		}
+		s := usageSample{Time: time.Now(), AC: ac}
+		if bat, err := getBattery(); err == nil {
+			s.Capacity = bat.capacity
+		}
+		if history {
+			if err := appendUsage(s); err != nil {
+				logTrace.Println(err)
+			}
+		}
+		samples = append(samples, s)
+		for len(samples) > 0 && s.Time.Sub(samples[0].Time) >= usageKeep {
+			samples = samples[1:]
+		}
+		if history {
+			updateRecommendation(samples)
+		}
+		updateHealth(samples, time.Now())
+	}
+}
+
+func updateRecommendation(samples []usageSample) {
+	rec := recommend(samples, time.Now())
+	recommended.Lock()
+	changed := (rec == nil) != (recommended.rec == nil) || (rec != nil && *rec != *recommended.rec)
+	recommended.rec = rec
+	recommended.Unlock()
+	if changed {
+		stateChanged()
+	}
+}
+
+// unpluggedShare returns the share of samples taken on battery, in percent,
+// for weekdays and weekends
+func unpluggedShare(samples []usageSample) (weekdays, weekends int) {
+	var n, off [2]int
+	for _, s := range samples {
+		i := 0
+		if wd := s.Time.Weekday(); wd == time.Saturday || wd == time.Sunday {
+			i = 1
+		}
+		n[i]++
+		if !s.AC {
+			off[i]++
+		}
+	}
+	share := func(i int) int {
+		if n[i] == 0 {
+			return -1
+		}
+		return off[i] * 100 / n[i]
+	}
+	return share(0), share(1)
+}
+
+// recommend suggests a preset based on how often the laptop runs on
+// battery, or returns nil if there's not enough history
+func recommend(samples []usageSample, now time.Time) *recommendation {
+	if len(samples) == 0 || now.Sub(samples[0].Time) < usageMinHistory {
+		return nil
+	}
+	weekdays, weekends := unpluggedShare(samples)
+	worst := weekdays
+	if weekends > worst {
+		worst = weekends
+	}
+
+	var name string
+	switch {
+	case worst < 5:
+		name = "home"
+	case worst < 25:
+		name = "office"
+	default:
+		name = "travel"
+	}
+	p, _ := findPreset(name)
+
+	var parts []string
+	if weekdays >= 0 {
+		parts = append(parts, localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "UsageWeekdays", Other: "{{.Share}}% of the time on weekdays"}, TemplateData: map[string]interface{}{"Share": weekdays}}))
+	}
+	if weekends >= 0 {
+		parts = append(parts, localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "UsageWeekends", Other: "{{.Share}}% of the time on weekends"}, TemplateData: map[string]interface{}{"Share": weekends}}))
+	}
+	reason := localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "UsageReason", Other: "Over the last {{.Days}} days the laptop ran on battery {{.Shares}}"}, TemplateData: map[string]interface{}{"Days": int(now.Sub(samples[0].Time).Hours() / 24), "Shares": strings.Join(parts, ", ")}})
+	return &recommendation{preset: p, reason: reason}
+}
+
+// currentRecommendation returns the recommendation unless the thresholds
+// are already as recommended or the policy doesn't allow them
+func currentRecommendation() *recommendation {
+	recommended.Lock()
+	rec := recommended.rec
+	recommended.Unlock()
+	if rec == nil || policy.checkPreset(rec.preset.name) != nil {
+		return nil
+	}
+	if s := cachedStatus(); s.threshErr == nil && sameThresholds(s.min, s.max, rec.preset.min, rec.preset.max) {
+		return nil
+	}
+	return rec
+}
+
+// recommendationTitle returns the title for the UI element that applies
+// the recommendation
+func recommendationTitle(rec *recommendation) string {
+	return localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "Recommended", Other: "Suggested: {{.Preset}} ({{.Min}}%-{{.Max}}%), click to apply"}, TemplateData: map[string]interface{}{"Preset": strings.ToUpper(rec.preset.name), "Min": rec.preset.min, "Max": rec.preset.max}})
+}
+
+// applyRecommendation sets the recommended thresholds
+func applyRecommendation() error {
+	rec := currentRecommendation()
+	if rec == nil {
+		return nil
+	}
+	logInfo.Printf("applying suggested thresholds %d-%d", rec.preset.min, rec.preset.max)
+	err := setThresholds(rec.preset.min, rec.preset.max, sourceUser)
+	stateChanged()
+	return err
+}
@@ -0,0 +1,62 @@
+// Copyright (C) 2026 Evgeny Kuznetsov (evgeny@kuznetsov.md)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecommend(t *testing.T) {
+	// Monday
+	start := time.Date(2026, 10, 5, 0, 0, 0, 0, time.UTC)
+	var samples []usageSample
+	for i := 0; i < 8*24; i++ {
+		samples = append(samples, usageSample{Time: start.Add(time.Duration(i) * time.Hour), AC: true})
+	}
+	now := start.Add(8 * 24 * time.Hour)
+
+	if rec := recommend(samples[:24], start.Add(24*time.Hour)); rec != nil {
+		t.Fatalf("recommended without enough history: %v", rec)
+	}
+	if rec := recommend(samples, now); rec == nil || rec.preset.name != "home" {
+		t.Fatalf("want home, got: %v", rec)
+	}
+
+	// unplugged for 8 hours on Saturday
+	for i := 5*24 + 10; i < 5*24+18; i++ {
+		samples[i].AC = false
+	}
+	if rec := recommend(samples, now); rec == nil || rec.preset.name != "office" {
+		t.Fatalf("want office, got: %v", rec)
+	}
+}
+
+func TestUsageHistory(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	now := time.Now()
+	old := usageSample{Time: now.Add(-usageKeep - time.Hour), AC: true, Capacity: 70}
+	recent := usageSample{Time: now.Add(-time.Hour), AC: false, Capacity: 50}
+	for _, s := range []usageSample{old, recent} {
+		if err := appendUsage(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	samples, err := loadUsage(now)
+	if err != nil || len(samples) != 1 || samples[0].AC || samples[0].Capacity != 50 {
+		t.Fatalf("want: [%v], got: %v, %v", recent, samples, err)
+	}
+}